@@ -123,14 +123,12 @@ func UnlockAchievementCallback(apiReq *APIRequest) {
 		apiReq.Player.Name, response.Message))
 }
 
+// OnPlayerKill unlocks the achievements related to kills. Scoring is done by
+// the game mode.
 func OnPlayerKill(killed, killer *Player) {
 	if killer.Equals(killed) {
 		// Achivement: A Special Kind of Stupid
 		UnlockAchievement(killed, 8)
-
-		if killer.Score > 0 {
-			killer.Score--
-		}
 		return
 	}
 
@@ -141,7 +139,6 @@ func OnPlayerKill(killed, killer *Player) {
 	UnlockAchievement(killer, 2)
 
 	killer.Victims++
-	killer.Score++
 
 	if killer.Victims == 5 {
 		// Achievement: Getting used to it
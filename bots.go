@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+const (
+	// Prefix of the accounts of bots, which can't be used by players
+	BotAccountPrefix = "bot:"
+	// Weapon used by bots, when it exists in the weapon table
+	BotWeapon = 2
+	// Damage and fire rate of bots without weapon table
+	BotDamage   = 10
+	BotFireRate = 2
+	// Distance at which bots see and shoot their enemies
+	BotSightRange = 60
+	BotFireRange  = 40
+	// Speed of bots, in units per second
+	BotSpeed = 8
+	// Chance of a shot of a bot to hit its target
+	BotAccuracy = 0.4
+	// Distance at which a bot has reached the spawn point it wanders to
+	botArrivalDistance = 2
+)
+
+// Bot is the state of the artificial intelligence of a bot player
+type Bot struct {
+	Number int
+	// Enemy being chased, if any
+	Target *Player
+	// Point the bot wanders to when it has no target
+	Destination    [3]float32
+	HasDestination bool
+}
+
+// IsBot checks if a player is controlled by the server
+func (p *Player) IsBot() bool {
+	return p.Bot != nil
+}
+
+// AddBot creates a bot player and makes it join the game
+func AddBot() (*Player, error) {
+	if !config.ServerHealth {
+		return nil, errors.New("bots need the server to handle health")
+	}
+	if playing, _ := PlayerCounts(); playing >= config.MaxPlayers {
+		return nil, errors.New("the server is full")
+	}
+	id := byte(0)
+	for ; id < 255; id++ {
+		if _, ok := players[id]; !ok {
+			break
+		}
+	}
+	number := 1
+	for findBot(number) != nil {
+		number++
+	}
+
+	bot := &Player{
+		Name:        "Bot " + strconv.Itoa(number),
+		Account:     BotAccountPrefix + strconv.Itoa(number),
+		Address:     &Address{},
+		Bot:         &Bot{Number: number},
+		Initialized: true,
+	}
+	bot.CurrentWeapon = BotWeapon
+	players[id] = bot
+	gameMode.OnJoin(bot)
+	bot.PlaceOnMap()
+	UpdatePlayerList()
+
+	log.Info(bot.Name + " has joined the game!")
+	SendMessage(bot.Name + " has joined the game!")
+	return bot, nil
+}
+
+// KickBot removes the last bot added to the game. It returns false when there
+// is no bot to remove.
+func KickBot() bool {
+	var last *Player
+	for _, currentPlayer := range players {
+		if currentPlayer.IsBot() &&
+			(last == nil || currentPlayer.Bot.Number > last.Bot.Number) {
+			last = currentPlayer
+		}
+	}
+	if last == nil {
+		return false
+	}
+	last.Remove()
+	SendMessage(last.Name + " has left the server.")
+	return true
+}
+
+// CheckBots adds or removes bots so that the number of players reaches the
+// bot_fill setting
+func CheckBots() {
+	if config.BotFill <= 0 || !config.ServerHealth {
+		return
+	}
+	count, _ := PlayerCounts()
+	bots := 0
+	for _, currentPlayer := range players {
+		if currentPlayer.IsBot() {
+			bots++
+		}
+	}
+	fill := config.BotFill
+	if fill > config.MaxPlayers {
+		fill = config.MaxPlayers
+	}
+	if count < fill {
+		AddBot()
+	} else if count > fill && bots > 0 {
+		KickBot()
+	}
+}
+
+// findBot returns the bot with a given number
+func findBot(number int) *Player {
+	for _, currentPlayer := range players {
+		if currentPlayer.IsBot() && currentPlayer.Bot.Number == number {
+			return currentPlayer
+		}
+	}
+	return nil
+}
+
+// Think makes a bot chase and shoot the nearest enemy, or wander between the
+// spawn points of the map
+func (p *Player) Think(now time.Time) {
+	if !p.IsBot() || !p.IsAlive() {
+		return
+	}
+	bot := p.Bot
+	bot.Target = p.nearestEnemy()
+
+	var destination [3]float32
+	if bot.Target != nil {
+		destination = [3]float32{bot.Target.X, bot.Target.Y, bot.Target.Z}
+		if p.distance(destination[0], destination[1],
+			destination[2]) <= BotFireRange {
+			p.botShoot(now)
+		}
+	} else {
+		if !bot.HasDestination || p.distance(bot.Destination[0],
+			bot.Destination[1], bot.Destination[2]) < botArrivalDistance {
+			spawns := CurrentMapDefinition().Spawns
+			spawn := spawns[rand.Intn(len(spawns))]
+			bot.Destination = [3]float32{spawn.X, spawn.Y, spawn.Z}
+			bot.HasDestination = true
+		}
+		destination = bot.Destination
+	}
+	p.moveTowards(destination)
+	p.LastUpdate = now
+}
+
+// nearestEnemy returns the closest living enemy seen by a bot
+func (p *Player) nearestEnemy() *Player {
+	var nearest *Player
+	nearestDistance := float32(BotSightRange)
+	for _, currentPlayer := range players {
+		if currentPlayer.Equals(p) || currentPlayer.IsTeammate(p) ||
+			!currentPlayer.Initialized || !currentPlayer.IsAlive() ||
+			!p.CanSee(currentPlayer) {
+			continue
+		}
+		distance := p.distance(currentPlayer.X, currentPlayer.Y,
+			currentPlayer.Z)
+		if distance <= nearestDistance {
+			nearest, nearestDistance = currentPlayer, distance
+		}
+	}
+	return nearest
+}
+
+// moveTowards makes a bot walk to a point and face it, using the physics of
+// the map
+func (p *Player) moveTowards(destination [3]float32) {
+	dx, dz := destination[0]-p.X, destination[2]-p.Z
+	length := float32(math.Hypot(float64(dx), float64(dz)))
+	if length > 0 {
+		p.XVelocity, p.ZVelocity = dx/length*BotSpeed, dz/length*BotSpeed
+		p.YRotation = float32(math.Atan2(float64(dx), float64(dz)) *
+			180 / math.Pi)
+	} else {
+		p.XVelocity, p.ZVelocity = 0, 0
+	}
+	body := p.body()
+	CurrentMapDefinition().Step(body, tickRateSecs)
+	p.setBody(body)
+}
+
+// botShoot makes a bot shoot its target when its weapon is ready
+func (p *Player) botShoot(now time.Time) {
+	target := p.Bot.Target
+	damage, interval := BotDamage, time.Second/BotFireRate
+	weapon, ok := weapons[p.CurrentWeapon]
+	if ok {
+		interval = weapon.FireInterval()
+	}
+	if now.Sub(p.LastShot) < interval {
+		return
+	}
+
+	if ok && weapon.HasProjectiles() {
+		direction := [3]float32{target.X - p.X, target.Y - p.Y,
+			target.Z - p.Z}
+		FireProjectile(p, p.CurrentWeapon, [3]float32{p.X, p.Y, p.Z},
+			direction)
+		return
+	}
+	p.LastShot = now
+	if ok {
+		damage = weapon.MaxDamage(p.distance(target.X, target.Y, target.Z))
+	}
+	if damage > 0 && rand.Float64() < BotAccuracy {
+		InflictDamage(p, target, damage)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestBots(t *testing.T) {
+	previousConfig, previousMode := config, gameMode
+	previousMap, previousDefinitions := currentMap, mapDefinitions
+	previousPlayers, previousLog := players, log
+	udpNetworkInput := UdpNetworkInput
+	testConfig := defaultConfig
+	config = &testConfig
+	gameMode = NewDeathmatchMode()
+	currentMap = "test"
+	mapDefinitions = map[string]*MapDefinition{
+		"test": DefaultMapDefinition("test")}
+	human := newTestPlayer("human")
+	players = map[byte]*Player{0: human}
+	// Chat messages are logged and sent through the UDP queue
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	tickRateSecs = 0.015
+	defer func() {
+		config, gameMode = previousConfig, previousMode
+		currentMap, mapDefinitions = previousMap, previousDefinitions
+		log.Close()
+		players, log = previousPlayers, previousLog
+		UdpNetworkInput = udpNetworkInput
+		tickRateSecs = 0
+		os.Remove("test.log")
+	}()
+
+	bot, err := AddBot()
+	if err != nil || len(players) != 2 || !bot.IsAlive() {
+		t.Log("Couldn't add a bot:", err)
+		t.FailNow()
+	}
+	if id, ok := bot.Id(); !ok || players[id] != bot {
+		t.Log("The bot does not take a slot in the player list")
+		t.Fail()
+	}
+
+	// Bots chase the nearest enemy and shoot
+	human.X, human.Y, human.Z = bot.X+5, bot.Y, bot.Z
+	x := bot.X
+	for i := 0; i < 100 && human.Health == MaxHealth; i++ {
+		bot.LastShot = time.Time{}
+		bot.Think(time.Now())
+	}
+	if bot.Bot.Target != human || bot.X <= x {
+		t.Log("The bot is not chasing its enemy")
+		t.Fail()
+	}
+	if human.Health == MaxHealth {
+		t.Log("The bot has never hit its enemy")
+		t.Fail()
+	}
+
+	// Without enemy, bots wander between spawn points
+	human.X = 500
+	bot.Think(time.Now())
+	if bot.Bot.Target != nil || !bot.Bot.HasDestination {
+		t.Log("The bot is not wandering")
+		t.Fail()
+	}
+
+	// Bots fill the server up to a number of players
+	testConfig.BotFill = 3
+	for i := 0; i < 5; i++ {
+		CheckBots()
+	}
+	if len(players) != 3 || findBot(2) == nil {
+		t.Log("Wrong player count with bot fill:", len(players))
+		t.Fail()
+	}
+	testConfig.BotFill = 1
+	for i := 0; i < 5; i++ {
+		CheckBots()
+	}
+	if len(players) != 1 || KickBot() {
+		t.Log("Bots have not left the server:", len(players))
+		t.Fail()
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
 var (
 	CommandHandlers = make(map[string]interface{})
+	// Commands that players can run without being operators
+	PlayerCommands = make(map[string]bool)
 )
 
 // CommandParser is the routine that parses stdin commands
@@ -36,6 +39,19 @@ func SetupCommandHandlers() {
 	RegisterCommandHandler("deop", HandleDeopCommand)
 	RegisterCommandHandler("players", HandlePlayersCommand)
 	RegisterCommandHandler("godmode", HandleGodmodeCommand)
+	RegisterCommandHandler("map", HandleMapCommand)
+	RegisterCommandHandler("nextmap", HandleNextmapCommand)
+	RegisterCommandHandler("team", HandleTeamCommand)
+	RegisterCommandHandler("match", HandleMatchCommand)
+	RegisterCommandHandler("ready", HandleReadyCommand)
+	RegisterCommandHandler("instance", HandleInstanceCommand)
+	RegisterCommandHandler("bot", HandleBotCommand)
+	RegisterCommandHandler("spectate", HandleSpectateCommand)
+	RegisterCommandHandler("play", HandlePlayCommand)
+
+	AllowPlayerCommand("ready")
+	AllowPlayerCommand("spectate")
+	AllowPlayerCommand("play")
 
 	AllowClientCommand("debug")
 	AllowClientCommand("noclip")
@@ -47,6 +63,11 @@ func RegisterCommandHandler(command string, handler interface{}) {
 	CommandHandlers[command] = handler
 }
 
+// AllowPlayerCommand allows players who are not operators to run a command
+func AllowPlayerCommand(command string) {
+	PlayerCommands[command] = true
+}
+
 // AllowClientCommand allows some commands to be interpreted by the client
 // instead of being executed by the server
 func AllowClientCommand(command string) {
@@ -83,7 +104,7 @@ func HandleCommand(rawArgs string, sender *Player) {
 		return
 	}
 
-	if sender != nil && !sender.IsOperator() {
+	if sender != nil && !sender.IsOperator() && !PlayerCommands[args[0]] {
 		sender.SendMessage("You are not allowed to run commands on the " +
 			"server.")
 		return
@@ -183,6 +204,7 @@ Usage: /op <player>`
 Please specify a single player for safety reasons.`
 	}
 	config.Operators = append(config.Operators, players[0].Account)
+	MarkScoreboardDirty()
 	players[0].SendMessage("You are now a server operator.")
 	return players[0].Name + " has been granted operator powers."
 }
@@ -212,6 +234,7 @@ Please specify a single player for safety reasons.`
 		newOperators = append(newOperators, currentOperator)
 	}
 	config.Operators = newOperators
+	MarkScoreboardDirty()
 	players[0].SendMessage("You are not a server operator anymore.")
 	return players[0].Name + " has lost his operator powers."
 }
@@ -230,6 +253,8 @@ func HandlePlayersCommand(args []string, p *Player) string {
 
 func HandleGodmodeCommand(args []string, p *Player) string {
 	if len(args) == 0 && p != nil {
+		// The god mode of the spawn protection is not toggled
+		p.EndSpawnProtection()
 		if p.Godmode {
 			p.Godmode = false
 			return "God mode has been disabled"
@@ -246,6 +271,7 @@ func HandleGodmodeCommand(args []string, p *Player) string {
 		return "No player was found!"
 	}
 	for _, currentPlayer := range pl {
+		currentPlayer.EndSpawnProtection()
 		if currentPlayer.Godmode {
 			currentPlayer.Godmode = false
 			currentPlayer.SendMessage("Your god mode has been disabled")
@@ -257,3 +283,215 @@ func HandleGodmodeCommand(args []string, p *Player) string {
 	}
 	return ""
 }
+
+// HandleMapCommand changes the current map
+// Usage: map <name>
+func HandleMapCommand(args []string, p *Player) string {
+	if len(args) != 1 {
+		return `map: Changes the current map
+Usage: map <name>`
+	}
+	if _, ok := mapDefinitions[args[0]]; !ok {
+		def, err := LoadMapDefinition(args[0])
+		if err != nil {
+			return "Couldn't load " + args[0] + ": " + err.Error()
+		}
+		mapDefinitions[args[0]] = def
+	}
+	ChangeMap(args[0])
+	return "Map changed to " + args[0]
+}
+
+// HandleNextmapCommand ends the current map, or skips the intermission
+// Usage: nextmap
+func HandleNextmapCommand(args []string, p *Player) string {
+	if match.IsOver() {
+		rotation.Next()
+		return "Map changed to " + currentMap
+	}
+	match.End()
+	return "Map ended, next map in " + strconv.Itoa(config.Intermission) +
+		" seconds"
+}
+
+// HandleTeamCommand moves a player to another team
+// Usage: team <player> <none|red|blue>
+func HandleTeamCommand(args []string, p *Player) string {
+	if len(args) != 2 {
+		return `team: Moves a player to another team
+Usage: team <player> <none|red|blue>`
+	}
+	team, err := ParseTeam(args[1])
+	if err != nil {
+		return err.Error()
+	}
+	pl := MatchPlayers(args[0])
+	if len(pl) == 0 {
+		return "No player was found!"
+	}
+	for _, currentPlayer := range pl {
+		currentPlayer.SetTeam(team)
+		currentPlayer.SendMessage("You have been moved to the " +
+			TeamName(team))
+	}
+	return "Moved " + strconv.Itoa(len(pl)) + " player(s) to the " +
+		TeamName(team)
+}
+
+// HandleMatchCommand controls the phases of the match
+// Usage: match <start|pause|restart|end>
+func HandleMatchCommand(args []string, p *Player) string {
+	if len(args) != 1 {
+		return `match: Controls the match
+Usage: match <start|pause|restart|end>`
+	}
+	switch args[0] {
+	case "start":
+		if match.Phase != MatchWarmup {
+			return "The match is not in warmup."
+		}
+		match.Start()
+		return "Match started"
+	case "pause":
+		if match.Pause() {
+			return "Match paused"
+		} else if match.Phase == MatchPostMatch {
+			return "The match is already over."
+		}
+		return "Match resumed"
+	case "restart":
+		match.Restart()
+		return "Match restarted"
+	case "end":
+		if match.IsOver() {
+			return "The match is already over."
+		}
+		match.End()
+		return "Match ended"
+	}
+	return "Unknown match action " + args[0]
+}
+
+// HandleReadyCommand marks a player as ready to start the match
+// Usage: ready
+func HandleReadyCommand(args []string, p *Player) string {
+	if p == nil {
+		return "The console cannot be ready."
+	}
+	if match.Phase != MatchWarmup {
+		return "The match has already started."
+	}
+	match.SetReady(p, !match.Ready[p.Account])
+	return ""
+}
+
+// HandleInstanceCommand moves a player to another instance
+// Usage: instance <player> <main|all|id>
+func HandleInstanceCommand(args []string, p *Player) string {
+	if len(args) != 2 {
+		return `instance: Moves a player to another instance
+Usage: instance <player> <main|all|id>`
+	}
+	var instance byte
+	switch args[1] {
+	case "main":
+		instance = MainInstance
+	case "all":
+		if !config.InstanceSpectators {
+			return "Spectators across instances are disabled."
+		}
+		instance = InstanceAll
+	default:
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id < 0 || id >= InstanceAll {
+			return "Invalid instance " + args[1]
+		}
+		instance = byte(id)
+	}
+	pl := MatchPlayers(args[0])
+	if len(pl) == 0 {
+		return "No player was found!"
+	}
+	for _, currentPlayer := range pl {
+		if session := FindMinigame(currentPlayer); session != nil {
+			session.End(nil)
+		}
+		currentPlayer.Instance = instance
+	}
+	return "Moved " + strconv.Itoa(len(pl)) + " player(s) to instance " +
+		args[1]
+}
+
+// HandleBotCommand adds bots to the game or removes them
+// Usage: bot <add|kick> [count]
+func HandleBotCommand(args []string, p *Player) string {
+	if len(args) == 0 || len(args) > 2 ||
+		(args[0] != "add" && args[0] != "kick") {
+		return `bot: Adds bots to the game or removes them
+Usage: bot <add|kick> [count]`
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return "Invalid bot count " + args[1]
+		}
+		count = n
+	}
+	done := 0
+	for ; done < count; done++ {
+		if args[0] == "kick" {
+			if !KickBot() {
+				break
+			}
+		} else if _, err := AddBot(); err != nil {
+			if done == 0 {
+				return "Couldn't add a bot: " + err.Error()
+			}
+			break
+		}
+	}
+	if args[0] == "kick" {
+		return "Kicked " + strconv.Itoa(done) + " bot(s)"
+	}
+	return "Added " + strconv.Itoa(done) + " bot(s)"
+}
+
+// HandleSpectateCommand makes players spectate the game
+// Usage: spectate [player]
+func HandleSpectateCommand(args []string, p *Player) string {
+	return switchPlayers("spectate", args, p, (*Player).Spectate)
+}
+
+// HandlePlayCommand moves spectators back into the game
+// Usage: play [player]
+func HandlePlayCommand(args []string, p *Player) string {
+	return switchPlayers("play", args, p, (*Player).Play)
+}
+
+// switchPlayers applies a change to the players matching the argument of a
+// command, or to the sender without argument. Players who are not operators
+// may only change themselves.
+func switchPlayers(command string, args []string, p *Player,
+	change func(*Player) error) string {
+	if len(args) > 1 || (len(args) == 0 && p == nil) {
+		return command + `: Moves players between playing and spectating
+Usage: ` + command + ` [player]`
+	}
+	targets := []*Player{p}
+	if len(args) == 1 {
+		if p != nil && !p.IsOperator() {
+			return "You can only use this command for yourself."
+		}
+		targets = MatchPlayers(args[0])
+		if len(targets) == 0 {
+			return "No player was found!"
+		}
+	}
+	for _, currentPlayer := range targets {
+		if err := change(currentPlayer); err != nil {
+			return "Couldn't move " + currentPlayer.Name + ": " + err.Error()
+		}
+	}
+	return "Moved " + strconv.Itoa(len(targets)) + " player(s)"
+}
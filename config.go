@@ -16,18 +16,57 @@ import (
 
 var (
 	defaultConfig = DeimosConfig{
-		Name:           "Deimos server",
-		Host:           net.IPv4(0, 0, 0, 0),
-		Port:           1518,
-		MaxPlayers:     16,
-		Maps:           []string{"d_compound"},
-		Operators:      []string{},
-		Verbose:        false,
-		LogFile:        "server.log",
-		AutoInsecure:   false,
-		RegisterServer: true,
-		Tickrate:       15,
-		Insecure:       false,
+		Name:                  "Deimos server",
+		Host:                  net.IPv4(0, 0, 0, 0),
+		Port:                  1518,
+		MaxPlayers:            16,
+		MaxSpectators:         4,
+		Maps:                  []string{"d_compound"},
+		MapsDir:               "maps",
+		MapOrder:              MapOrderSequential,
+		GameMode:              "ffa",
+		FriendlyFire:          FriendlyFireOff,
+		AutoBalance:           true,
+		FlagReturnTime:        30,
+		CaptureLimit:          3,
+		WarmupTime:            60,
+		MinPlayers:            2,
+		Overtime:              120,
+		AssistThreshold:       25,
+		MultiKillWindow:       3000,
+		WeaponsFile:           "weapons.json",
+		ChallengeTimeout:      15,
+		MinigameTimeout:       300,
+		MinigameScore:         1,
+		InstanceSpectators:    false,
+		InterestRadius:        0,
+		BandwidthBudget:       32000,
+		BotFill:               0,
+		MapModes:              []string{},
+		TimeLimit:             15,
+		ScoreLimit:            30,
+		Intermission:          10,
+		Operators:             []string{},
+		Verbose:               false,
+		LogFile:               "server.log",
+		AutoInsecure:          false,
+		RegisterServer:        true,
+		Tickrate:              15,
+		Insecure:              false,
+		LagCompensation:       true,
+		MaxRewind:             500,
+		HitboxRadius:          0.6,
+		HitboxHeight:          2,
+		ServerHealth:          true,
+		RespawnDelay:          3000,
+		SpawnProtection:       3000,
+		MaxSpeed:              25,
+		MaxAcceleration:       150,
+		MaxPositionChange:     10,
+		MovementPolicy:        MovementPolicyCorrect,
+		MaxMovementViolations: 20,
+		InputMovement:         false,
+		ExtrapolationLimit:    250,
 	}
 	// Simplified default config elements
 	writtenElements = map[string]bool{
@@ -40,18 +79,63 @@ var (
 )
 
 type DeimosConfig struct {
-	Name           string
-	Host           net.IP
-	Port           int
-	MaxPlayers     int
-	Maps           []string
-	Operators      []string
-	Verbose        bool
-	LogFile        string
-	AutoInsecure   bool
-	RegisterServer bool
-	Tickrate       int
-	Insecure       bool
+	Name               string
+	Host               net.IP
+	Port               int
+	MaxPlayers         int
+	MaxSpectators      int
+	Maps               []string
+	MapsDir            string
+	MapOrder           string
+	GameMode           string
+	FriendlyFire       string
+	AutoBalance        bool
+	FlagReturnTime     int
+	CaptureLimit       int
+	WarmupTime         int
+	MinPlayers         int
+	Overtime           int
+	AssistThreshold    int
+	MultiKillWindow    int
+	WeaponsFile        string
+	ChallengeTimeout   int
+	MinigameTimeout    int
+	MinigameScore      int
+	InstanceSpectators bool
+	InterestRadius     int
+	BandwidthBudget    int
+	BotFill            int
+	MapModes           []string
+	TimeLimit          int
+	ScoreLimit         int
+	Intermission       int
+	Operators          []string
+	Verbose            bool
+	LogFile            string
+	AutoInsecure       bool
+	RegisterServer     bool
+	Tickrate           int
+	Insecure           bool
+
+	// Hit validation
+	LagCompensation bool
+	MaxRewind       int
+	HitboxRadius    float64
+	HitboxHeight    float64
+
+	// Health and deaths handled by the server instead of the clients
+	ServerHealth    bool
+	RespawnDelay    int
+	SpawnProtection int
+
+	// Movement validation
+	MaxSpeed              float64
+	MaxAcceleration       float64
+	MaxPositionChange     float64
+	MovementPolicy        string
+	MaxMovementViolations int
+	InputMovement         bool
+	ExtrapolationLimit    int
 }
 
 // LoadConfig tries to load config from the disk or creates it if necessary
@@ -106,6 +190,13 @@ func LoadConfig() {
 			}
 			field.Set(reflect.ValueOf(fieldValue.(int)))
 
+		case "float64":
+			val, err := cfg.GetFloat64("default", fieldName)
+			if err == nil {
+				fieldValue = val
+			}
+			field.Set(reflect.ValueOf(fieldValue.(float64)))
+
 		case "bool":
 			val, err := cfg.GetBool("default", fieldName)
 			if err == nil {
@@ -138,7 +229,7 @@ func LoadConfig() {
 	}
 
 	// Additional loading operations
-	tickRateSecs = float32(config.Tickrate / 1000)
+	tickRateSecs = float32(config.Tickrate) / 1000
 }
 
 // GetConfigItem returns a string representation of a config item
@@ -155,6 +246,8 @@ func GetConfigItem(name string) (string, error) {
 			return fieldValue.(string), nil
 		case "int":
 			return strconv.Itoa(fieldValue.(int)), nil
+		case "float64":
+			return strconv.FormatFloat(fieldValue.(float64), 'f', -1, 64), nil
 		case "bool":
 			if fieldValue.(bool) {
 				return "on", nil
@@ -236,6 +329,9 @@ func WriteDefaultConfig() {
 			cfg.AddOption("default", fieldName, fieldValue.(string))
 		case "int":
 			cfg.AddOption("default", fieldName, strconv.Itoa(fieldValue.(int)))
+		case "float64":
+			cfg.AddOption("default", fieldName,
+				strconv.FormatFloat(fieldValue.(float64), 'f', -1, 64))
 		case "bool":
 			stringValue := "off"
 			if fieldValue.(bool) {
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+const (
+	LifeStateDead = byte(iota)
+	LifeStateAlive
+
+	MaxHealth = 100
+	MaxArmor  = 100
+	// Part of the damage absorbed by the armor, in percents
+	ArmorAbsorption = 66
+)
+
+// IsAlive checks if a player is currently alive
+func (p *Player) IsAlive() bool {
+	return p.LifeState != LifeStateDead
+}
+
+// InflictDamage applies damage done by a player to another one, following the
+// friendly fire policy. Players only hurt players of their own instance.
+func InflictDamage(attacker, victim *Player, damage int) {
+	if victim.Godmode || victim.Spectating || attacker.Spectating ||
+		!attacker.CanSee(victim) || attacker.Instance != victim.Instance {
+		return
+	}
+	victimDamage, reflected := FriendlyFireDamage(attacker, victim, damage)
+
+	if config.ServerHealth {
+		// Attacking ends the spawn protection
+		attacker.EndSpawnProtection()
+		victim.Damage(attacker, victimDamage)
+		attacker.Damage(attacker, reflected)
+		return
+	}
+
+	// Legacy path: forward the damage packet to the victim
+	if victimDamage > 0 {
+		sendLegacyDamage(victim, attacker, victimDamage)
+	}
+	if reflected > 0 {
+		sendLegacyDamage(attacker, attacker, reflected)
+	}
+}
+
+// sendLegacyDamage forwards damage to a player who computes the health
+// by itself
+func sendLegacyDamage(victim, attacker *Player, damage int) {
+	damagePacket := packet.New(packet.PacketTypeTCP, 0x0C)
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, int32(damage))
+	damagePacket.AddField(buf.Bytes())
+	victim.Send(damagePacket)
+
+	// Save the damage
+	victim.LastDamage = &DamageData{
+		Player: attacker,
+		Damage: damage,
+	}
+	victim.RecordDamage(attacker, damage)
+}
+
+// Damage applies validated damage to a player and kills the player if needed
+func (p *Player) Damage(attacker *Player, damage int) {
+	if damage <= 0 || p.Godmode || !p.IsAlive() ||
+		!match.AllowsDamage() {
+		return
+	}
+	if damage = gameMode.OnDamage(p, attacker, damage); damage <= 0 {
+		return
+	}
+
+	// The armor absorbs part of the damage until it is depleted
+	absorbed := damage * ArmorAbsorption / 100
+	if absorbed > int(p.Armor) {
+		absorbed = int(p.Armor)
+	}
+	p.Armor -= byte(absorbed)
+	damage -= absorbed
+
+	health := int(p.Health) - damage
+	if health < 0 {
+		health = 0
+	}
+	p.Health = byte(health)
+	p.LastDamage = &DamageData{
+		Player: attacker,
+		Damage: damage,
+	}
+	p.RecordDamage(attacker, damage)
+
+	p.sendDamage(damage)
+	if p.Health == 0 {
+		p.Die(attacker)
+	}
+}
+
+// sendDamage notifies a player of damage (0x0C): [damage][health][armor].
+// Healing is sent as negative damage.
+func (p *Player) sendDamage(damage int) {
+	damagePacket := packet.New(packet.PacketTypeTCP, 0x0C)
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, int32(damage))
+	damagePacket.AddField(buf.Bytes())
+	damagePacket.AddFieldBytes(p.Health, p.Armor)
+	p.Send(damagePacket)
+}
+
+// Die kills a player, broadcasts the death and schedules the respawn
+func (p *Player) Die(killer *Player) {
+	if !p.IsAlive() {
+		return
+	}
+	if killer == nil {
+		killer = p
+	}
+	p.LifeState = LifeStateDead
+	p.Health = 0
+	p.Deaths++
+	p.RespawnTime = time.Now().Add(time.Duration(config.RespawnDelay) *
+		time.Millisecond)
+	p.EndSpawnProtection()
+	// Flags are dropped even when kills do not count
+	if dropper, ok := gameMode.(FlagDropper); ok {
+		dropper.DropFlag(p)
+	}
+
+	if killer.Equals(p) {
+		log.Infof("%s died.", p.Name)
+	} else {
+		log.Infof("%s was killed by %s.", p.Name, killer.Name)
+	}
+
+	BroadcastKill(p, killer)
+}
+
+// Respawn brings a player back to life with full health, on a spawn point
+// chosen by the server
+func (p *Player) Respawn() {
+	p.LifeState = LifeStateAlive
+	p.Health = MaxHealth
+	p.Armor = 0
+	p.LastDamage = nil
+	p.DamageHistory = nil
+	p.RespawnTime = time.Time{}
+	p.MoveToSpawn()
+
+	// Spawn protection
+	if config.SpawnProtection > 0 && !p.Godmode {
+		p.Godmode = true
+		p.SpawnProtection = time.Now().Add(
+			time.Duration(config.SpawnProtection) * time.Millisecond)
+	}
+
+	respawnPacket := packet.New(packet.PacketTypeTCP, 0x0F)
+	respawnPacket.AddFieldBytes(p.Health, p.Armor)
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, []float32{p.X, p.Y, p.Z,
+		p.YRotation})
+	respawnPacket.AddField(buf.Bytes())
+	p.Send(respawnPacket)
+	MarkScoreboardDirty()
+	p.MovementGrace = time.Now().Add(p.Latency + movementGrace)
+}
+
+// CheckRespawn respawns a dead player once the respawn delay is over
+func (p *Player) CheckRespawn() {
+	if !config.ServerHealth || p.IsAlive() || p.RespawnTime.IsZero() ||
+		time.Now().Before(p.RespawnTime) || !p.CanRespawn() {
+		return
+	}
+	p.Respawn()
+}
+
+// CanRespawn checks if a dead player is allowed to respawn. Spectators never
+// respawn, and the rules of the game mode only apply once the match is being
+// played.
+func (p *Player) CanRespawn() bool {
+	return !p.Spectating && (!match.IsPlaying() || gameMode.CanRespawn(p))
+}
+
+// BroadcastKill sends the kill packet (0x0D) to everybody and credits the
+// killer. The kill packet is: [victim][killer][weapon][streak flag][streak]
+// [multi-kill][assist count][assist ids...]
+func BroadcastKill(victim, killer *Player) {
+	// The streak of the victim is reset when the kill is credited
+	if !victim.Equals(killer) && victim.CurrentStreak > 5 {
+		// Achievement: Instant cooling
+		UnlockAchievement(killer, 12)
+	}
+
+	// Kills do not count during the warmup
+	assisters := make([]*Player, 0)
+	if match.IsPlaying() {
+		assisters = CreditKill(victim, killer)
+	} else {
+		victim.CurrentStreak = 0
+		victim.DamageHistory = nil
+	}
+
+	// Kill packet, for Manu
+	killPacket := packet.New(packet.PacketTypeTCP, 0x0D)
+	victimId, authorId := byte(0), byte(0)
+	assistIds := make([]byte, 0)
+	for i, currentPlayer := range players {
+		if currentPlayer.Equals(victim) {
+			victimId = i
+		}
+		if currentPlayer.Equals(killer) {
+			authorId = i
+		}
+		for _, assister := range assisters {
+			if currentPlayer.Equals(assister) {
+				assistIds = append(assistIds, i)
+			}
+		}
+	}
+	killPacket.AddFieldBytes(victimId)
+	killPacket.AddFieldBytes(authorId)
+	if killer.Equals(victim) {
+		killPacket.AddFieldBytes(0xFF, 0x00, 0x00, 0x00)
+	} else {
+		killPacket.AddFieldBytes(killer.CurrentWeapon)
+		if killer.CurrentStreak > 5 {
+			killPacket.AddFieldBytes(0x01)
+		} else {
+			killPacket.AddFieldBytes(0x00)
+		}
+		killPacket.AddFieldBytes(clampByte(killer.CurrentStreak),
+			clampByte(killer.MultiKill))
+	}
+	killPacket.AddFieldBytes(byte(len(assistIds)))
+	killPacket.AddField(assistIds)
+	for _, currentPlayer := range players {
+		currentPlayer.Send(killPacket)
+	}
+
+	if match.IsPlaying() {
+		gameMode.OnKill(victim, killer)
+		OnPlayerKill(victim, killer)
+	}
+	MarkScoreboardDirty()
+}
+
+// clampByte converts a counter to a byte, keeping it at 255 at most
+func clampByte(n int) byte {
+	if n > 255 {
+		return 255
+	}
+	return byte(n)
+}
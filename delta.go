@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+)
+
+const (
+	deltaKindByte = iota
+	deltaKindFloat32
+	deltaKindString
+)
+
+var (
+	playerEncoder *DeltaEncoder
+	entityEncoder *DeltaEncoder
+)
+
+// DeltaEncoder encodes the replicated fields (the ones with a prefix tag) of a
+// struct type into world packets. Fields are described once, at startup, so
+// that encoding only reads them by index.
+type DeltaEncoder struct {
+	Type   reflect.Type
+	Fields []DeltaField
+}
+
+// DeltaField describes a replicated field of a struct
+type DeltaField struct {
+	Name   string
+	Prefix []byte
+	Index  int
+	Kind   int
+}
+
+// SetupDeltaEncoders builds the encoders of every replicated type
+func SetupDeltaEncoders() error {
+	var err error
+	playerEncoder, err = NewDeltaEncoder(reflect.TypeOf(PlayerState{}))
+	if err != nil {
+		return err
+	}
+	entityEncoder, err = NewDeltaEncoder(reflect.TypeOf(EntityState{}))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewDeltaEncoder describes the replicated fields of a struct type using their
+// prefix tags. Duplicate prefixes and unsupported types are errors.
+func NewDeltaEncoder(t reflect.Type) (*DeltaEncoder, error) {
+	encoder := &DeltaEncoder{Type: t}
+	prefixes := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		prefix := field.Tag.Get("prefix")
+		if prefix == "" {
+			continue
+		}
+		if other, ok := prefixes[prefix]; ok {
+			return nil, errors.New("Duplicate prefix " + prefix + " in " +
+				t.Name() + " (" + other + " and " + field.Name + ")")
+		}
+		prefixes[prefix] = field.Name
+
+		var kind int
+		switch field.Type.Kind() {
+		case reflect.Uint8:
+			kind = deltaKindByte
+		case reflect.Float32:
+			kind = deltaKindFloat32
+		case reflect.String:
+			kind = deltaKindString
+		default:
+			return nil, errors.New("Unsupported type " +
+				field.Type.String() + " for replicated field " + t.Name() +
+				"." + field.Name)
+		}
+		encoder.Fields = append(encoder.Fields, DeltaField{
+			Name:   field.Name,
+			Prefix: []byte(prefix),
+			Index:  i,
+			Kind:   kind,
+		})
+	}
+	return encoder, nil
+}
+
+// EncodePlayer creates a player element of the world packet. previous can be
+// nil, in which case every field is sent.
+func EncodePlayer(header []byte, current, previous *PlayerState) []byte {
+	if previous == nil {
+		return playerEncoder.encode(header, reflect.ValueOf(current).Elem(),
+			reflect.Value{})
+	}
+	return playerEncoder.encode(header, reflect.ValueOf(current).Elem(),
+		reflect.ValueOf(previous).Elem())
+}
+
+// EncodeEntity creates an entity element of the world packet. previous can be
+// nil, in which case every field is sent.
+func EncodeEntity(header []byte, current, previous *EntityState) []byte {
+	if previous == nil {
+		return entityEncoder.encode(header, reflect.ValueOf(current).Elem(),
+			reflect.Value{})
+	}
+	return entityEncoder.encode(header, reflect.ValueOf(current).Elem(),
+		reflect.ValueOf(previous).Elem())
+}
+
+// encode writes the fields that changed between two values of the encoder's
+// type. previous is the zero Value when every field is sent. Every field is
+// prefixed by the header of the element.
+func (d *DeltaEncoder) encode(header []byte, current,
+	previous reflect.Value) []byte {
+	buf := make([]byte, 0, 64)
+	for _, field := range d.Fields {
+		value := current.Field(field.Index)
+		var previousValue reflect.Value
+		if previous.IsValid() {
+			previousValue = previous.Field(field.Index)
+		}
+
+		switch field.Kind {
+		case deltaKindByte:
+			if previousValue.IsValid() && value.Uint() == previousValue.Uint() {
+				continue
+			}
+			buf = append(buf, header...)
+			buf = append(buf, field.Prefix...)
+			buf = append(buf, byte(value.Uint()))
+		case deltaKindFloat32:
+			if previousValue.IsValid() &&
+				value.Float() == previousValue.Float() {
+				continue
+			}
+			buf = append(buf, header...)
+			buf = append(buf, field.Prefix...)
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:],
+				math.Float32bits(float32(value.Float())))
+			buf = append(buf, b[:]...)
+		case deltaKindString:
+			if previousValue.IsValid() &&
+				value.String() == previousValue.String() {
+				continue
+			}
+			buf = append(buf, header...)
+			buf = append(buf, field.Prefix...)
+			buf = append(buf, value.String()...)
+			buf = append(buf, 0x00)
+		}
+	}
+	return buf
+}
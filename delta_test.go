@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// reflectDeltaPacket is the reflection-based encoder the delta encoders
+// replaced, kept as a reference for tests and benchmarks
+func reflectDeltaPacket(header []byte, current, previous interface{}) []byte {
+	buf := bytes.NewBuffer(nil)
+
+	val := reflect.ValueOf(current).Elem()
+	var val2 reflect.Value
+	if previous != nil {
+		val2 = reflect.ValueOf(previous).Elem()
+	}
+	for i := 0; i < val.NumField(); i++ {
+		fieldValue1, fieldType := val.Field(i).Interface(),
+			val.Type().Field(i)
+		prefix := []byte(fieldType.Tag.Get("prefix"))
+		if len(prefix) == 0 {
+			continue
+		}
+		if previous != nil && fieldValue1 == val2.Field(i).Interface() {
+			continue
+		}
+		buf.Write(header)
+		buf.Write(prefix)
+		switch fieldType.Type.String() {
+		case "string":
+			buf.Write([]byte(fieldValue1.(string)))
+			buf.WriteByte(0x00)
+		case "float32":
+			binary.Write(buf, binary.LittleEndian, fieldValue1.(float32))
+		case "byte", "uint8":
+			buf.WriteByte(fieldValue1.(byte))
+		}
+	}
+	return buf.Bytes()
+}
+
+func testPlayers() (*PlayerState, *PlayerState) {
+	p1 := &PlayerState{X: 1, Y: 2, Z: 3, XVelocity: 4, ZVelocity: 5,
+		Health: 100, CurrentWeapon: 2}
+	p2 := *p1
+	p2.X, p2.ZVelocity, p2.Health = 1.5, 0, 50
+	return p1, &p2
+}
+
+func TestDeltaEncoder(t *testing.T) {
+	if err := SetupDeltaEncoders(); err != nil {
+		t.Log(err)
+		t.Fail()
+		return
+	}
+	p1, p2 := testPlayers()
+	header := []byte{'A', 3}
+	if !bytes.Equal(EncodePlayer(header, p2, p1),
+		reflectDeltaPacket(header, p2, p1)) ||
+		!bytes.Equal(EncodePlayer(header, p2, nil),
+			reflectDeltaPacket(header, p2, nil)) {
+		t.Log("Player encoding differs from the reflection encoder")
+		t.Fail()
+	}
+	if len(EncodePlayer(header, p1, p1)) != 0 {
+		t.Log("Unchanged fields have been encoded")
+		t.Fail()
+	}
+
+	e1 := &EntityState{X: 1, ModelId: "crate"}
+	e2 := &EntityState{X: 2, ModelId: "barrel"}
+	header = []byte{'E', 1, 0}
+	if !bytes.Equal(EncodeEntity(header, e2, e1),
+		reflectDeltaPacket(header, e2, e1)) {
+		t.Log("Entity encoding differs from the reflection encoder")
+		t.Fail()
+	}
+}
+
+func TestDeltaEncoderDuplicatePrefix(t *testing.T) {
+	type duplicate struct {
+		A byte `prefix:"A"`
+		B byte `prefix:"A"`
+	}
+	if _, err := NewDeltaEncoder(reflect.TypeOf(duplicate{})); err == nil {
+		t.Log("Duplicate prefixes have not been detected")
+		t.Fail()
+	}
+	type unsupported struct {
+		A int `prefix:"A"`
+	}
+	if _, err := NewDeltaEncoder(reflect.TypeOf(unsupported{})); err == nil {
+		t.Log("An unsupported field type has not been detected")
+		t.Fail()
+	}
+}
+
+func BenchmarkDeltaEncoder(b *testing.B) {
+	SetupDeltaEncoders()
+	p1, p2 := testPlayers()
+	header := []byte{'A', 3}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		EncodePlayer(header, p2, p1)
+		EncodePlayer(header, p2, nil)
+	}
+}
+
+func BenchmarkReflectDeltaPacket(b *testing.B) {
+	p1, p2 := testPlayers()
+	header := []byte{'A', 3}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reflectDeltaPacket(header, p2, p1)
+		reflectDeltaPacket(header, p2, nil)
+	}
+}
@@ -1,31 +1,133 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
 	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+var (
+	nextEntityId uint16 = 0
 )
 
 type Entity struct {
-	UUID string
+	UUID  string
+	NetId uint16
+
+	// Replicated values
+	EntityState
+
+	// Whether or not the entity is subject to gravity and collisions
+	Physics bool
+	// Half size of the box used for its collisions
+	Extents [3]float32
+	// Whether or not the entity ignores gravity
+	Weightless bool
 
+	LastUpdate time.Time
+}
+
+// EntityState contains the values of an entity sent to players in world
+// packets
+type EntityState struct {
 	// Position
-	X float32
-	Y float32
-	Z float32
+	X float32 `prefix:"X"`
+	Y float32 `prefix:"Y"`
+	Z float32 `prefix:"Z"`
 	// Rotation
-	XRotation float32
-	YRotation float32
-	ZRotation float32
+	XRotation float32 `prefix:"P"`
+	YRotation float32 `prefix:"Q"`
+	ZRotation float32 `prefix:"O"`
 	// Velocity
-	XVelocity        float32
-	YVelocity        float32
-	ZVelocity        float32
-	XAngularVelocity float32
-	YAngularVelocity float32
-	ZAngularVelocity float32
+	XVelocity        float32 `prefix:"R"`
+	YVelocity        float32 `prefix:"S"`
+	ZVelocity        float32 `prefix:"T"`
+	XAngularVelocity float32 `prefix:"U"`
+	YAngularVelocity float32 `prefix:"V"`
+	ZAngularVelocity float32 `prefix:"N"`
 
-	ModelId string
+	ModelId string `prefix:"M"`
+	// Whether or not the entity is shown (1) or hidden (0)
+	Active byte `prefix:"A"`
+}
 
-	LastUpdate time.Time
+// SpawnEntity creates a new entity owned by the server and notifies all the
+// players of its existence
+func SpawnEntity(modelId string, x, y, z float32) *Entity {
+	e := &Entity{
+		UUID:  NewUUID(),
+		NetId: newEntityId(),
+		EntityState: EntityState{
+			X:       x,
+			Y:       y,
+			Z:       z,
+			ModelId: modelId,
+			Active:  1,
+		},
+		LastUpdate: time.Now(),
+	}
+	entities[e.NetId] = e
+	spawnPacket := e.SpawnPacket()
+	for _, currentPlayer := range players {
+		currentPlayer.Send(spawnPacket)
+	}
+	return e
+}
+
+// FindEntity returns the entity matching an UUID
+func FindEntity(uuid string) (*Entity, bool) {
+	for _, e := range entities {
+		if e.UUID == uuid {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// SendEntities sends the spawn packets of all existing entities to a player
+func SendEntities(p *Player) {
+	for _, e := range entities {
+		p.Send(e.SpawnPacket())
+	}
+}
+
+// Update changes the position and the velocity of an entity
+func (e *Entity) Update(x, y, z, xVelocity, yVelocity, zVelocity float32) {
+	e.X, e.Y, e.Z = x, y, z
+	e.XVelocity, e.YVelocity, e.ZVelocity = xVelocity, yVelocity, zVelocity
+	e.LastUpdate = time.Now()
+}
+
+// Destroy removes an entity from the world and notifies all the players
+func (e *Entity) Destroy() {
+	// The id may have been given to another entity since
+	if current, ok := entities[e.NetId]; !ok || current != e {
+		return
+	}
+	delete(entities, e.NetId)
+	destroyPacket := packet.New(packet.PacketTypeTCP, 0x12)
+	destroyPacket.AddField(e.netIdBytes())
+	for _, currentPlayer := range players {
+		currentPlayer.Send(destroyPacket)
+	}
+}
+
+// SpawnPacket creates the packet (0x11) telling a client that an entity exists
+func (e *Entity) SpawnPacket() *packet.Packet {
+	spawnPacket := packet.New(packet.PacketTypeTCP, 0x11)
+	spawnPacket.AddField(e.netIdBytes())
+	spawnPacket.AddFieldString(e.UUID)
+	spawnPacket.AddFieldString(e.ModelId)
+	return spawnPacket
+}
+
+// Equals checks whether or not an entity is another entity
+func (e *Entity) Equals(e2 *Entity) bool {
+	return e.UUID == e2.UUID
 }
 
 // NextTick computes the state of the entity at the world's next tick
@@ -34,11 +136,50 @@ func (e *Entity) NextTick() {
 		return
 	}
 
-	e.X = e.X + e.XVelocity*tickRateSecs
-	e.Y = e.Y + e.YVelocity*tickRateSecs
-	e.Z = e.Z + e.ZVelocity*tickRateSecs
+	if e.Physics {
+		body := e.body()
+		CurrentMapDefinition().Step(body, tickRateSecs)
+		e.setBody(body)
+	} else {
+		e.X = e.X + e.XVelocity*tickRateSecs
+		e.Y = e.Y + e.YVelocity*tickRateSecs
+		e.Z = e.Z + e.ZVelocity*tickRateSecs
+	}
 
 	e.XRotation = e.XRotation + e.XAngularVelocity*tickRateSecs
 	e.YRotation = e.YRotation + e.YAngularVelocity*tickRateSecs
 	e.ZRotation = e.ZRotation + e.ZAngularVelocity*tickRateSecs
 }
+
+// netIdBytes returns the network id of an entity as it is sent to clients
+func (e *Entity) netIdBytes() []byte {
+	return entityIdBytes(e.NetId)
+}
+
+// entityIdBytes encodes the network id of an entity
+func entityIdBytes(id uint16) []byte {
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, id)
+	return buf.Bytes()
+}
+
+// newEntityId finds an unused network id for a new entity
+func newEntityId() uint16 {
+	for {
+		id := nextEntityId
+		nextEntityId++
+		if _, ok := entities[id]; !ok {
+			return id
+		}
+	}
+}
+
+// NewUUID generates a random (version 4) UUID
+func NewUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0F | 0x40
+	b[8] = b[8]&0x3F | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10],
+		b[10:])
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+// Game events, sent in game event packets (0x16)
+const (
+	GameEventFlagTaken = byte(iota)
+	GameEventFlagDropped
+	GameEventFlagReturned
+	GameEventFlagCaptured
+	GameEventPickup
+	GameEventExplosion
+)
+
+// NoPlayerId is sent instead of a player id when an event has no player
+const NoPlayerId = 0xFF
+
+// BroadcastGameEvent tells all the players that something happened in the
+// game (0x16): [event][info][player id][extra...]. The info is the team of the
+// flag for flag events, the kind of item for pickups and the weapon for
+// explosions, followed by their position. The player may be nil.
+func BroadcastGameEvent(event, info byte, p *Player, extra ...byte) {
+	playerId := byte(NoPlayerId)
+	for i, currentPlayer := range players {
+		if p != nil && currentPlayer.Equals(p) {
+			playerId = i
+			break
+		}
+	}
+	eventPacket := packet.New(packet.PacketTypeTCP, 0x16)
+	eventPacket.AddFieldBytes(event, info, playerId)
+	eventPacket.AddField(extra)
+	for _, currentPlayer := range players {
+		currentPlayer.Send(eventPacket)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+)
+
+var (
+	GameModes = make(map[string]func() GameMode)
+	gameMode  GameMode
+)
+
+// GameMode defines the rules of the game played on the current map
+type GameMode interface {
+	// Name returns the name of the mode, as used in config files
+	Name() string
+	// OnJoin is called when a player joins the game, before spawning
+	OnJoin(p *Player)
+	// OnLeave is called when a player leaves the game
+	OnLeave(p *Player)
+	// OnKill is called when a player dies. The killer is the victim itself
+	// for suicides.
+	OnKill(killed, killer *Player)
+	// OnDamage is called before applying damage to a player and returns the
+	// damage to actually apply (0 to cancel it)
+	OnDamage(victim, attacker *Player, damage int) int
+	// OnTick is called at every tick of the world simulation
+	OnTick()
+	// OnRoundEnd is called when the current match is over
+	OnRoundEnd()
+	// OnEnd is called before the mode is replaced, to remove what it added
+	// to the world
+	OnEnd()
+	// CanRespawn checks if a dead player is allowed to respawn
+	CanRespawn(p *Player) bool
+	// Leader returns the name and the score of the leading player or team,
+	// with an empty name when there is a tie
+	Leader() (string, int)
+}
+
+// BaseGameMode implements the hooks of GameMode that modes often leave empty
+type BaseGameMode struct{}
+
+func (m *BaseGameMode) OnJoin(p *Player)                                  {}
+func (m *BaseGameMode) OnLeave(p *Player)                                 {}
+func (m *BaseGameMode) OnTick()                                           {}
+func (m *BaseGameMode) OnRoundEnd()                                       {}
+func (m *BaseGameMode) OnEnd()                                            {}
+func (m *BaseGameMode) CanRespawn(p *Player) bool                         { return true }
+func (m *BaseGameMode) OnDamage(victim, attacker *Player, damage int) int { return damage }
+
+// SetupGameModes registers the built-in game modes
+func SetupGameModes() {
+	RegisterGameMode("ffa", NewDeathmatchMode)
+	RegisterGameMode("tdm", NewTeamDeathmatchMode)
+	RegisterGameMode("lms", NewLastManStandingMode)
+	RegisterGameMode("ctf", NewCaptureTheFlagMode)
+}
+
+// RegisterGameMode adds/edits a game mode
+func RegisterGameMode(name string, constructor func() GameMode) {
+	GameModes[name] = constructor
+}
+
+// ModeForMap finds the game mode to use on a map, from the config and the
+// modes allowed by the map definition
+func ModeForMap(name string) string {
+	mode := config.GameMode
+	for _, mapMode := range config.MapModes {
+		split := strings.SplitN(strings.TrimSpace(mapMode), ":", 2)
+		if len(split) == 2 && split[0] == name {
+			mode = split[1]
+		}
+	}
+	if _, ok := GameModes[mode]; !ok {
+		log.Warn("Unknown game mode " + mode + " for map " + name)
+		mode = defaultConfig.GameMode
+	}
+
+	// Check that the map supports this mode
+	def, ok := mapDefinitions[name]
+	if !ok || len(def.Modes) == 0 {
+		return mode
+	}
+	for _, allowedMode := range def.Modes {
+		if allowedMode == mode {
+			return mode
+		}
+	}
+	for _, allowedMode := range def.Modes {
+		if _, ok := GameModes[allowedMode]; ok {
+			log.Warn("Map " + name + " does not support " + mode +
+				", using " + allowedMode + " instead")
+			return allowedMode
+		}
+	}
+	log.Warn("Map " + name + " does not support any known game mode")
+	return mode
+}
+
+// SetGameMode changes the game mode and makes all players join it
+func SetGameMode(name string) {
+	if gameMode != nil {
+		gameMode.OnEnd()
+	}
+	gameMode = GameModes[name]()
+	for _, currentPlayer := range players {
+		currentPlayer.Team = TeamNone
+		if currentPlayer.IsPlaying() {
+			gameMode.OnJoin(currentPlayer)
+		}
+	}
+	UpdatePlayerList()
+	log.Info("Game mode: " + name)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Distance under which a player touches a flag
+	FlagPickupRadius = 2
+	// Points given to a player who captures a flag
+	CaptureScore = 5
+)
+
+// States of a flag
+const (
+	FlagAtBase = byte(iota)
+	FlagCarried
+	FlagDropped
+)
+
+// Flag is a flag of a capture the flag game, represented by an entity
+type Flag struct {
+	Team     byte
+	Home     FlagPoint
+	State    byte
+	Carrier  *Player
+	DropTime time.Time
+	Entity   *Entity
+}
+
+// FlagDropper is implemented by game modes whose players carry flags, which
+// they drop when they die
+type FlagDropper interface {
+	DropFlag(p *Player)
+}
+
+// CaptureTheFlagMode opposes two teams who must bring the flag of the other
+// team to their own flag
+type CaptureTheFlagMode struct {
+	BaseGameMode
+	Flags    []*Flag
+	Captures [3]int
+	// Captures of each player, by account
+	PlayerCaptures map[string]int
+	flagsReady     bool
+}
+
+// NewCaptureTheFlagMode creates a capture the flag game
+func NewCaptureTheFlagMode() GameMode {
+	return &CaptureTheFlagMode{PlayerCaptures: make(map[string]int)}
+}
+
+func (m *CaptureTheFlagMode) Name() string {
+	return "ctf"
+}
+
+func (m *CaptureTheFlagMode) OnJoin(p *Player) {
+	p.Team = SmallestTeam(p)
+}
+
+func (m *CaptureTheFlagMode) OnLeave(p *Player) {
+	m.DropFlag(p)
+	p.Team = TeamNone
+}
+
+func (m *CaptureTheFlagMode) OnKill(killed, killer *Player) {
+	if killer.Equals(killed) || killer.IsTeammate(killed) {
+		if killer.Score > 0 {
+			killer.Score--
+		}
+		return
+	}
+	killer.Score++
+}
+
+func (m *CaptureTheFlagMode) OnTick() {
+	BalanceTeams()
+	if !m.flagsReady {
+		m.SpawnFlags()
+	}
+	for _, flag := range m.Flags {
+		m.updateFlag(flag)
+	}
+}
+
+func (m *CaptureTheFlagMode) OnEnd() {
+	for _, flag := range m.Flags {
+		flag.Entity.Destroy()
+	}
+}
+
+func (m *CaptureTheFlagMode) Leader() (string, int) {
+	return teamLeader(m.Captures)
+}
+
+// Columns adds the captures of each player to the scoreboard
+func (m *CaptureTheFlagMode) Columns() []string {
+	return []string{"captures"}
+}
+
+func (m *CaptureTheFlagMode) ColumnValues(p *Player) []int {
+	return []int{m.PlayerCaptures[p.Account]}
+}
+
+// SpawnFlags creates the flags of the current map at their home positions
+func (m *CaptureTheFlagMode) SpawnFlags() {
+	m.flagsReady = true
+	def := CurrentMapDefinition()
+	if len(def.Flags) == 0 {
+		log.Warn("Map " + def.Name + " has no flag to capture")
+	}
+	for _, home := range def.Flags {
+		m.Flags = append(m.Flags, &Flag{
+			Team:   home.Team,
+			Home:   home,
+			State:  FlagAtBase,
+			Entity: SpawnEntity(flagModel(home.Team), home.X, home.Y, home.Z),
+		})
+	}
+}
+
+// DropFlag drops the flag carried by a player, if any
+func (m *CaptureTheFlagMode) DropFlag(p *Player) {
+	for _, flag := range m.Flags {
+		if flag.State != FlagCarried || !flag.Carrier.Equals(p) {
+			continue
+		}
+		flag.State = FlagDropped
+		flag.Carrier = nil
+		flag.DropTime = time.Now()
+		flag.Entity.Update(p.X, p.Y, p.Z, 0, 0, 0)
+		SendMessage(p.Name + " dropped the " + flagName(flag.Team) + ".")
+		BroadcastGameEvent(GameEventFlagDropped, flag.Team, p)
+	}
+}
+
+// CarriedFlag returns the flag carried by a player, if any
+func (m *CaptureTheFlagMode) CarriedFlag(p *Player) *Flag {
+	for _, flag := range m.Flags {
+		if flag.State == FlagCarried && flag.Carrier.Equals(p) {
+			return flag
+		}
+	}
+	return nil
+}
+
+// updateFlag moves a flag with its carrier, returns it when it has been
+// dropped for too long and checks the players touching it
+func (m *CaptureTheFlagMode) updateFlag(flag *Flag) {
+	switch flag.State {
+	case FlagCarried:
+		carrier := flag.Carrier
+		flag.Entity.Update(carrier.X, carrier.Y, carrier.Z, carrier.XVelocity,
+			carrier.YVelocity, carrier.ZVelocity)
+		return
+	case FlagDropped:
+		if time.Since(flag.DropTime) >= time.Duration(config.FlagReturnTime)*
+			time.Second {
+			m.returnFlag(flag, nil)
+			return
+		}
+	}
+
+	for _, currentPlayer := range players {
+		if !currentPlayer.Initialized || !currentPlayer.IsAlive() ||
+			currentPlayer.Team == TeamNone || !touchesFlag(currentPlayer, flag) {
+			continue
+		}
+		if currentPlayer.Team != flag.Team {
+			m.takeFlag(flag, currentPlayer)
+			return
+		}
+		if flag.State == FlagDropped {
+			m.returnFlag(flag, currentPlayer)
+			return
+		}
+		// The flag is at its base: its team captures the carried flag
+		if enemyFlag := m.CarriedFlag(currentPlayer); enemyFlag != nil {
+			m.captureFlag(enemyFlag, currentPlayer)
+		}
+	}
+}
+
+// takeFlag gives a flag to a player of the other team
+func (m *CaptureTheFlagMode) takeFlag(flag *Flag, p *Player) {
+	flag.State = FlagCarried
+	flag.Carrier = p
+	// Carrying a flag is an attack
+	p.EndSpawnProtection()
+	SendMessage(p.Name + " has taken the " + flagName(flag.Team) + "!")
+	BroadcastGameEvent(GameEventFlagTaken, flag.Team, p)
+}
+
+// returnFlag puts a flag back at its base. The player is nil when the flag
+// has been returned automatically.
+func (m *CaptureTheFlagMode) returnFlag(flag *Flag, p *Player) {
+	flag.State = FlagAtBase
+	flag.Carrier = nil
+	flag.Entity.Update(flag.Home.X, flag.Home.Y, flag.Home.Z, 0, 0, 0)
+	if p == nil {
+		SendMessage("The " + flagName(flag.Team) + " has returned to its base.")
+	} else {
+		SendMessage(p.Name + " has returned the " + flagName(flag.Team) + ".")
+	}
+	BroadcastGameEvent(GameEventFlagReturned, flag.Team, p)
+}
+
+// captureFlag scores a capture for the team of the carrier of a flag
+func (m *CaptureTheFlagMode) captureFlag(flag *Flag, p *Player) {
+	m.returnFlag(flag, nil)
+	m.Captures[p.Team]++
+	m.PlayerCaptures[p.Account]++
+	p.Score += CaptureScore
+	MarkScoreboardDirty()
+	SendMessage(p.Name + " has captured the " + flagName(flag.Team) + "!")
+	BroadcastGameEvent(GameEventFlagCaptured, flag.Team, p)
+
+	if config.CaptureLimit > 0 && m.Captures[p.Team] >= config.CaptureLimit &&
+		match.IsPlaying() {
+		match.End()
+	}
+}
+
+// touchesFlag checks if a player is close enough to a flag to pick it up
+func touchesFlag(p *Player, flag *Flag) bool {
+	dx, dy, dz := p.X-flag.Entity.X, p.Y-flag.Entity.Y, p.Z-flag.Entity.Z
+	return dx*dx+dy*dy+dz*dz <= FlagPickupRadius*FlagPickupRadius
+}
+
+// flagName returns the display name of the flag of a team
+func flagName(team byte) string {
+	switch team {
+	case TeamRed:
+		return "red flag"
+	case TeamBlue:
+		return "blue flag"
+	}
+	return "flag"
+}
+
+// flagModel returns the model of the flag of a team
+func flagModel(team byte) string {
+	switch team {
+	case TeamRed:
+		return "flag_red"
+	case TeamBlue:
+		return "flag_blue"
+	}
+	return "flag"
+}
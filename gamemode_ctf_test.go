@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestCaptureTheFlag(t *testing.T) {
+	previousConfig, previousMode, previousMap, previousDefinitions := config,
+		gameMode, currentMap, mapDefinitions
+	previousPlayers, previousEntities, previousLog := players, entities, log
+	previousMatch := match
+	udpNetworkInput := UdpNetworkInput
+	config = &defaultConfig
+	currentMap = "test"
+	mapDefinitions = map[string]*MapDefinition{"test": {
+		Name:   "test",
+		Spawns: []SpawnPoint{{Weight: 1}},
+		Flags: []FlagPoint{
+			{X: 0, Y: 0, Z: 50, Team: TeamRed},
+			{X: 0, Y: 0, Z: -50, Team: TeamBlue},
+		},
+	}}
+	red := newTestPlayer("red")
+	red.Team, red.Z = TeamRed, 40
+	players = map[byte]*Player{0: red}
+	entities = make(map[uint16]*Entity)
+	// Chat messages are logged and sent through the UDP queue
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	defer func() {
+		config, gameMode, currentMap, mapDefinitions = previousConfig,
+			previousMode, previousMap, previousDefinitions
+		log.Close()
+		players, entities, log = previousPlayers, previousEntities,
+			previousLog
+		match = previousMatch
+		UdpNetworkInput = udpNetworkInput
+		os.Remove("test.log")
+	}()
+
+	mode := NewCaptureTheFlagMode().(*CaptureTheFlagMode)
+	gameMode = mode
+	mode.OnTick()
+	if len(mode.Flags) != 2 || len(entities) != 2 {
+		t.Log("Flags have not been spawned")
+		t.FailNow()
+	}
+	blueFlag := mode.Flags[1]
+
+	// Take the flag of the blue team
+	red.Z = -49
+	mode.OnTick()
+	if blueFlag.State != FlagCarried || !blueFlag.Carrier.Equals(red) {
+		t.Log("The flag has not been taken")
+		t.FailNow()
+	}
+
+	// Bring it back to the red flag
+	red.Z = 49
+	mode.OnTick()
+	if blueFlag.State != FlagAtBase || mode.Captures[TeamRed] != 1 ||
+		red.Score != CaptureScore {
+		t.Log("The flag has not been captured")
+		t.FailNow()
+	}
+	if blueFlag.Entity.Z != -50 {
+		t.Log("The captured flag is not back at its base")
+		t.Fail()
+	}
+
+	// Dropped flags stay where their carrier died, even when kills do not
+	// count
+	match = &Match{Phase: MatchWarmup, Ready: make(map[string]bool)}
+	red.Z = -50
+	mode.OnTick()
+	red.Z = -20
+	red.Die(nil)
+	if blueFlag.State != FlagDropped || blueFlag.Entity.Z != -20 {
+		t.Log("The flag has not been dropped")
+		t.Fail()
+	}
+
+	// Flags are removed along with their mode when it is replaced
+	SetupGameModes()
+	SetGameMode("ctf")
+	gameMode.OnTick()
+	if len(entities) != 2 {
+		t.Log("The flags of the previous mode are still there:", len(entities))
+		t.Fail()
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+// DeathmatchMode is a free-for-all deathmatch: each kill gives a point to the
+// killer, each suicide removes one
+type DeathmatchMode struct {
+	BaseGameMode
+}
+
+// NewDeathmatchMode creates a free-for-all deathmatch game
+func NewDeathmatchMode() GameMode {
+	return &DeathmatchMode{}
+}
+
+func (m *DeathmatchMode) Name() string {
+	return "ffa"
+}
+
+func (m *DeathmatchMode) OnKill(killed, killer *Player) {
+	if killer.Equals(killed) {
+		if killer.Score > 0 {
+			killer.Score--
+		}
+		return
+	}
+	killer.Score++
+}
+
+func (m *DeathmatchMode) Leader() (string, int) {
+	return leadingPlayer()
+}
+
+// leadingPlayer returns the name and the score of the player with the highest
+// score, with an empty name when several players are tied
+func leadingPlayer() (string, int) {
+	name, score := "", -1
+	for _, currentPlayer := range players {
+		if !currentPlayer.IsPlaying() {
+			continue
+		}
+		if int(currentPlayer.Score) > score {
+			name, score = currentPlayer.Name, int(currentPlayer.Score)
+		} else if int(currentPlayer.Score) == score {
+			name = ""
+		}
+	}
+	if score < 0 {
+		score = 0
+	}
+	return name, score
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Time between the end of a round and the beginning of the next one
+	LastManStandingRoundDelay = 5 * time.Second
+)
+
+// LastManStandingMode is played in rounds without respawns: the last player
+// alive wins the round and gets a point
+type LastManStandingMode struct {
+	BaseGameMode
+	RoundActive bool
+	NextRound   time.Time
+}
+
+// NewLastManStandingMode creates a last man standing game
+func NewLastManStandingMode() GameMode {
+	return &LastManStandingMode{}
+}
+
+func (m *LastManStandingMode) Name() string {
+	return "lms"
+}
+
+func (m *LastManStandingMode) OnKill(killed, killer *Player) {}
+
+// CanRespawn only lets players respawn when there is not enough players to
+// play a round: players are respawned by the server when a round begins
+func (m *LastManStandingMode) CanRespawn(p *Player) bool {
+	return !m.RoundActive && !time.Now().Before(m.NextRound)
+}
+
+func (m *LastManStandingMode) OnTick() {
+	playing, alive := 0, make([]*Player, 0)
+	for _, currentPlayer := range players {
+		if !currentPlayer.IsPlaying() {
+			continue
+		}
+		playing++
+		if currentPlayer.IsAlive() {
+			alive = append(alive, currentPlayer)
+		}
+	}
+
+	if !m.RoundActive {
+		if playing < 2 || time.Now().Before(m.NextRound) {
+			return
+		}
+		m.startRound()
+		return
+	}
+
+	switch {
+	case playing < 2:
+		// Not enough players anymore
+		m.RoundActive = false
+	case len(alive) == 1:
+		alive[0].Score++
+		MarkScoreboardDirty()
+		SendMessage(alive[0].Name + " wins the round!")
+		m.endRound()
+	case len(alive) == 0:
+		SendMessage("Nobody survived this round!")
+		m.endRound()
+	}
+}
+
+func (m *LastManStandingMode) OnRoundEnd() {
+	m.RoundActive = false
+}
+
+func (m *LastManStandingMode) Leader() (string, int) {
+	return leadingPlayer()
+}
+
+// startRound respawns everybody at the beginning of a round
+func (m *LastManStandingMode) startRound() {
+	m.RoundActive = false
+	for _, currentPlayer := range players {
+		if currentPlayer.IsPlaying() {
+			currentPlayer.PlaceOnMap()
+		}
+	}
+	m.RoundActive = true
+	SendMessage("A new round begins!")
+}
+
+// endRound waits for a short delay before the next round
+func (m *LastManStandingMode) endRound() {
+	m.RoundActive = false
+	m.NextRound = time.Now().Add(LastManStandingRoundDelay)
+}
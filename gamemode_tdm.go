@@ -0,0 +1,45 @@
+package main
+
+// TeamDeathmatchMode opposes two teams: each kill gives a point to the team of
+// the killer
+type TeamDeathmatchMode struct {
+	BaseGameMode
+	TeamScores [3]int
+}
+
+// NewTeamDeathmatchMode creates a team deathmatch game
+func NewTeamDeathmatchMode() GameMode {
+	return &TeamDeathmatchMode{}
+}
+
+func (m *TeamDeathmatchMode) Name() string {
+	return "tdm"
+}
+
+func (m *TeamDeathmatchMode) OnJoin(p *Player) {
+	p.Team = SmallestTeam(p)
+}
+
+func (m *TeamDeathmatchMode) OnLeave(p *Player) {
+	p.Team = TeamNone
+}
+
+func (m *TeamDeathmatchMode) OnTick() {
+	BalanceTeams()
+}
+
+func (m *TeamDeathmatchMode) OnKill(killed, killer *Player) {
+	if killer.Equals(killed) || killer.Team == killed.Team {
+		// Suicides and team kills
+		if killer.Score > 0 {
+			killer.Score--
+		}
+		return
+	}
+	killer.Score++
+	m.TeamScores[killer.Team]++
+}
+
+func (m *TeamDeathmatchMode) Leader() (string, int) {
+	return teamLeader(m.TeamScores)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/deimosgame/deimos-server/packet"
+	"github.com/deimosgame/deimos-server/util"
+)
+
+// newTestPlayer creates a living player whose packets are kept in a buffer,
+// without adding it to the game
+func newTestPlayer(account string) *Player {
+	p := &Player{Name: account, Account: account, Address: &Address{},
+		Initialized: true, TCPNetworkInput: make(chan *packet.Packet, 1000)}
+	p.LifeState, p.Health = LifeStateAlive, MaxHealth
+	return p
+}
+
+func TestModeForMap(t *testing.T) {
+	previousConfig, previousDefinitions := config, mapDefinitions
+	testConfig := defaultConfig
+	testConfig.MapModes = []string{"d_arena:lms"}
+	config = &testConfig
+	SetupGameModes()
+	mapDefinitions = map[string]*MapDefinition{
+		"d_teams": {Name: "d_teams", Modes: []string{"tdm", "ffa"}},
+		"d_arena": {Name: "d_arena"},
+	}
+	defer func() {
+		config, mapDefinitions = previousConfig, previousDefinitions
+	}()
+
+	if mode := ModeForMap("d_teams"); mode != "ffa" {
+		t.Log("Expected the default mode, got", mode)
+		t.Fail()
+	}
+	if mode := ModeForMap("d_arena"); mode != "lms" {
+		t.Log("Expected the mode of the map from the config, got", mode)
+		t.Fail()
+	}
+}
+
+func TestTeamDeathmatchKill(t *testing.T) {
+	mode := NewTeamDeathmatchMode().(*TeamDeathmatchMode)
+	red := &Player{Name: "red", Account: "red"}
+	red.Team = TeamRed
+	red2 := &Player{Name: "red2", Account: "red2"}
+	red2.Team = TeamRed
+	blue := &Player{Name: "blue", Account: "blue"}
+	blue.Team = TeamBlue
+
+	mode.OnKill(blue, red)
+	if red.Score != 1 || mode.TeamScores[TeamRed] != 1 {
+		t.Log("Kill not counted for the red team")
+		t.Fail()
+	}
+	mode.OnKill(red2, red)
+	if red.Score != 0 || mode.TeamScores[TeamRed] != 1 {
+		t.Log("Team kill should remove a point from the player only")
+		t.Fail()
+	}
+	if name, score := mode.Leader(); name != TeamName(TeamRed) || score != 1 {
+		t.Log("Wrong leader:", name, score)
+		t.Fail()
+	}
+}
+
+func TestSpectatorsAreNotContestants(t *testing.T) {
+	previousPlayers := players
+	a, spectator := newTestPlayer("a"), newTestPlayer("spectator")
+	spectator.Spectating, spectator.Score = true, 10
+	players = map[byte]*Player{0: a, 1: spectator}
+	defer func() {
+		players = previousPlayers
+	}()
+
+	mode := NewLastManStandingMode().(*LastManStandingMode)
+	mode.OnTick()
+	if mode.RoundActive {
+		t.Log("A round has started with a single player and a spectator")
+		t.Fail()
+	}
+	if name, _ := NewDeathmatchMode().Leader(); name != "a" {
+		t.Log("Wrong leader with a spectator:", name)
+		t.Fail()
+	}
+}
+
+func TestSetGameModeSendsTeams(t *testing.T) {
+	previousMode, previousPlayers, previousLog := gameMode, players, log
+	udpNetworkInput := UdpNetworkInput
+	a := newTestPlayer("a")
+	players = map[byte]*Player{0: a}
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	SetupGameModes()
+	defer func() {
+		gameMode = previousMode
+		log.Close()
+		players, log = previousPlayers, previousLog
+		UdpNetworkInput = udpNetworkInput
+		os.Remove("test.log")
+	}()
+
+	SetGameMode("tdm")
+	team := TeamNone
+	for len(UdpNetworkInput) > 0 {
+		if message := <-UdpNetworkInput; message.Packet.Id == 0x06 {
+			team = message.Packet.Data[len(message.Packet.Data)-1]
+		}
+	}
+	if a.Team == TeamNone || team != a.Team {
+		t.Log("The new team has not been sent:", team, a.Team)
+		t.Fail()
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// Buttons of an input command
+const (
+	InputButtonJump = byte(1 << iota)
+	InputButtonSprint
+)
+
+const (
+	// Size of an input command in input packets (0x17)
+	InputCommandSize = 21
+	// Input commands waiting to be simulated, older ones being dropped
+	MaxPendingInputs = 32
+
+	// Speeds of players simulated from their inputs, in units per second
+	WalkSpeed   = 10
+	SprintSpeed = 16
+	JumpSpeed   = 5
+)
+
+// InputCommand is the state of the controls of a client during a tick:
+// [sequence][forward][strafe][yaw][pitch][buttons]
+type InputCommand struct {
+	Sequence uint32
+	// Movement axes, between -1 and 1
+	Forward float32
+	Strafe  float32
+	// View angles, in degrees
+	Yaw   float32
+	Pitch float32
+
+	Buttons byte
+}
+
+// InputAck is sent in world packets to players using the input movement mode:
+// [sequence][x, y, z][x, y, z velocity][grounded]
+type InputAck struct {
+	Sequence                        uint32
+	X, Y, Z                         float32
+	XVelocity, YVelocity, ZVelocity float32
+	Grounded                        byte
+}
+
+// InputAck returns the last simulated input command of a player and the
+// state of the player after it
+func (p *Player) InputAck() InputAck {
+	ack := InputAck{
+		Sequence:  p.InputSequence,
+		X:         p.X,
+		Y:         p.Y,
+		Z:         p.Z,
+		XVelocity: p.XVelocity,
+		YVelocity: p.YVelocity,
+		ZVelocity: p.ZVelocity,
+	}
+	if p.Grounded {
+		ack.Grounded = 1
+	}
+	return ack
+}
+
+// ReadInputCommands parses the input commands of an input packet, from the
+// oldest to the newest one
+func ReadInputCommands(data []byte) ([]InputCommand, error) {
+	if len(data) == 0 || len(data)%InputCommandSize != 0 {
+		return nil, errors.New("wrong input packet size")
+	}
+	commands := make([]InputCommand, len(data)/InputCommandSize)
+	err := binary.Read(bytes.NewReader(data), binary.LittleEndian, commands)
+	if err != nil {
+		return nil, err
+	}
+	for _, command := range commands {
+		for _, f := range []float32{command.Forward, command.Strafe,
+			command.Yaw, command.Pitch} {
+			if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+				return nil, errors.New("invalid values")
+			}
+		}
+	}
+	return commands, nil
+}
+
+// QueueInputs switches a player to the input movement mode and keeps the
+// commands that have not been received yet. Clients send their last commands
+// again in each packet, so that lost packets do not lose inputs.
+func (p *Player) QueueInputs(commands []InputCommand) {
+	p.InputMode = true
+	last := p.InputSequence
+	if len(p.PendingInputs) > 0 {
+		last = p.PendingInputs[len(p.PendingInputs)-1].Sequence
+	}
+	for _, command := range commands {
+		if command.Sequence <= last {
+			continue
+		}
+		p.PendingInputs = append(p.PendingInputs, command)
+		last = command.Sequence
+	}
+	if len(p.PendingInputs) > MaxPendingInputs {
+		p.PendingInputs = p.PendingInputs[len(p.PendingInputs)-
+			MaxPendingInputs:]
+	}
+}
+
+// ProcessInput simulates the oldest input command of a player using the
+// input movement mode. A single command is simulated each tick, so that
+// clients can't move faster by sending more commands.
+func (p *Player) ProcessInput(now time.Time) {
+	if !p.InputMode {
+		return
+	}
+	command := InputCommand{Sequence: p.InputSequence}
+	if len(p.PendingInputs) > 0 {
+		command = p.PendingInputs[0]
+		p.PendingInputs = p.PendingInputs[1:]
+	}
+	p.InputSequence = command.Sequence
+	if p.IsAlive() {
+		p.Simulate(&command, tickRateSecs)
+	}
+	p.LastUpdate = now
+}
+
+// Simulate moves a player according to an input command during dt seconds
+func (p *Player) Simulate(command *InputCommand, dt float32) {
+	forward, strafe := clampAxis(command.Forward), clampAxis(command.Strafe)
+	if length := math.Hypot(float64(forward), float64(strafe)); length > 1 {
+		forward, strafe = forward/float32(length), strafe/float32(length)
+	}
+	speed := float32(WalkSpeed)
+	if command.Buttons&InputButtonSprint != 0 {
+		speed = SprintSpeed
+	}
+	yaw := float64(command.Yaw) * math.Pi / 180
+	sin, cos := float32(math.Sin(yaw)), float32(math.Cos(yaw))
+
+	body := p.body()
+	body.Velocity[0] = (forward*sin + strafe*cos) * speed
+	body.Velocity[2] = (forward*cos - strafe*sin) * speed
+	if command.Buttons&InputButtonJump != 0 && p.Grounded {
+		body.Velocity[1] = JumpSpeed
+	}
+	CurrentMapDefinition().Step(body, dt)
+	p.setBody(body)
+	p.Grounded = body.Grounded
+	p.XRotation, p.YRotation = command.Pitch, command.Yaw
+	p.AngularVelocityX, p.AngularVelocityY = 0, 0
+}
+
+// clampAxis keeps a movement axis between -1 and 1
+func clampAxis(f float32) float32 {
+	if f > 1 {
+		return 1
+	} else if f < -1 {
+		return -1
+	}
+	return f
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestInputCommands(t *testing.T) {
+	def := DefaultMapDefinition("test")
+	def.Solids = []Box{{Min: [3]float32{-50, -1, -50},
+		Max: [3]float32{50, 0, 50}}}
+	previousConfig, previousMap, previousDefinitions := config, currentMap,
+		mapDefinitions
+	previousPlayers := players
+	config = &defaultConfig
+	currentMap = def.Name
+	mapDefinitions = map[string]*MapDefinition{def.Name: def}
+	p := newTestPlayer("a")
+	p.Y = PlayerExtents[1]
+	players = map[byte]*Player{0: p}
+	tickRateSecs = 0.1
+	defer func() {
+		config, currentMap, mapDefinitions = previousConfig, previousMap,
+			previousDefinitions
+		players = previousPlayers
+		tickRateSecs = 0
+	}()
+
+	encode := func(commands ...InputCommand) []byte {
+		buf := bytes.NewBuffer(nil)
+		binary.Write(buf, binary.LittleEndian, commands)
+		return buf.Bytes()
+	}
+	if _, err := ReadInputCommands(encode(InputCommand{
+		Forward: float32(math.NaN())})); err == nil {
+		t.Log("Invalid input commands have been accepted")
+		t.Fail()
+	}
+	commands, err := ReadInputCommands(encode(
+		InputCommand{Sequence: 1, Forward: 1, Yaw: 90},
+		InputCommand{Sequence: 2, Forward: 1, Yaw: 90,
+			Buttons: InputButtonJump}))
+	if err != nil || len(commands) != 2 {
+		t.Log("Couldn't read input commands:", err)
+		t.FailNow()
+	}
+
+	// Commands sent again are only queued once
+	p.QueueInputs(commands)
+	p.QueueInputs(commands[1:])
+	if !p.InputMode || len(p.PendingInputs) != 2 {
+		t.Log("Wrong pending inputs:", len(p.PendingInputs))
+		t.FailNow()
+	}
+
+	// A single command is simulated each tick
+	p.ProcessInput(time.Now())
+	if p.InputSequence != 1 || math.Abs(float64(p.X-1)) > 1e-4 ||
+		!p.Grounded {
+		t.Log("Wrong simulation of the first input:", p.X, p.Y)
+		t.Fail()
+	}
+	p.ProcessInput(time.Now())
+	if p.InputSequence != 2 || p.Y <= PlayerExtents[1] || p.Grounded {
+		t.Log("The player has not jumped:", p.Y)
+		t.Fail()
+	}
+	p.ProcessInput(time.Now())
+	if p.InputSequence != 2 || math.Abs(float64(p.X-2)) > 1e-4 {
+		t.Log("The player moved without input:", p.X)
+		t.Fail()
+	}
+
+	// The last simulated input is acknowledged in world packets, along with
+	// the state computed by the server
+	header := newSnapshotPacket(7, p).Data
+	var ack InputAck
+	err = binary.Read(bytes.NewReader(header[4:]), binary.LittleEndian, &ack)
+	if err != nil || len(header) != 33 || ack.Sequence != 2 ||
+		ack.X != p.X || ack.Y != p.Y || ack.YVelocity != p.YVelocity ||
+		ack.Grounded != 0 {
+		t.Log("Wrong world packet header:", header)
+		t.Fail()
+	}
+	if err := SetupDeltaEncoders(); err != nil {
+		t.Log("Couldn't set up delta encoders:", err)
+		t.FailNow()
+	}
+	snapshots = NewSnapshotRing(2)
+	for _, pkt := range snapshots.Save().Packet(p) {
+		if !bytes.Equal(pkt.Data[4:33], header[4:]) {
+			t.Log("The state of the player is missing from the snapshot")
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+const (
+	// Instance of the players who see every instance, when spectators are
+	// allowed across instances
+	InstanceAll = 0xFF
+)
+
+// CanSee checks if a player sees another one: players only see the players of
+// their own instance
+func (p *Player) CanSee(other *Player) bool {
+	return instanceVisible(p.Instance, other.Instance)
+}
+
+// instanceVisible checks if a player of an instance sees a player of another
+// instance
+func instanceVisible(viewer, target byte) bool {
+	return viewer == target ||
+		(viewer == InstanceAll && config.InstanceSpectators)
+}
+
+// SendInstanceMessage sends a chat message from a player to the players who
+// can see this player
+func SendInstanceMessage(sender *Player, message string) {
+	messagePacket := packet.New(packet.PacketTypeUDP, 0x03)
+	messagePacket.AddFieldString(message)
+	for _, currentPlayer := range players {
+		if currentPlayer.CanSee(sender) {
+			currentPlayer.Send(messagePacket)
+		}
+	}
+	if sender.Instance == MainInstance {
+		log.Info(message)
+	} else {
+		log.Info("[" + strconv.Itoa(int(sender.Instance)) + "] " + message)
+	}
+}
+
+// instanceOf returns the instance of a player in a snapshot
+func (s *Snapshot) instanceOf(account string) (byte, bool) {
+	for i := range s.Players {
+		if s.Players[i].Account == account {
+			return s.Players[i].Instance, true
+		}
+	}
+	return 0, false
+}
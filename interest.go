@@ -0,0 +1,186 @@
+package main
+
+import (
+	"sort"
+)
+
+const (
+	// Distance at which the priority of an element is halved
+	InterestFalloff = 50
+)
+
+// ClientView is what a client knows about the world: the snapshot holding
+// the last state of each element it acknowledged, the elements sent in each
+// snapshot, the elements removed from the interest of the client in each
+// snapshot and the priority accumulated by the elements that are waiting to
+// be sent
+type ClientView struct {
+	Known    map[uint32]uint32
+	Sent     map[uint32][]uint32
+	Removed  map[uint32][]uint32
+	Priority map[uint32]float64
+}
+
+// VisibilityVolume is a part of a map from which only some other volumes can
+// be seen
+type VisibilityVolume struct {
+	Name    string   `json:"name"`
+	Box     Box      `json:"box"`
+	Visible []string `json:"visible"`
+}
+
+// snapshotElement is a player or an entity waiting to be added to a snapshot
+// packet
+type snapshotElement struct {
+	Key      uint32
+	Data     []byte
+	Priority float64
+}
+
+// NewClientView creates the view of a client that does not know anything
+func NewClientView() *ClientView {
+	return &ClientView{
+		Known:    make(map[uint32]uint32),
+		Sent:     make(map[uint32][]uint32),
+		Removed:  make(map[uint32][]uint32),
+		Priority: make(map[uint32]float64),
+	}
+}
+
+// Acknowledge saves the states of the elements received by the client in a
+// snapshot as known
+func (v *ClientView) Acknowledge(id uint32) {
+	for _, key := range v.Sent[id] {
+		if known, ok := v.Known[key]; !ok || id > known {
+			v.Known[key] = id
+		}
+	}
+	// Elements removed from the interest of the client are sent entirely
+	// when they come back
+	for _, key := range v.Removed[id] {
+		if known, ok := v.Known[key]; ok && id > known {
+			delete(v.Known, key)
+		}
+	}
+	// Snapshots older than the acknowledged one will not be used anymore
+	for sentId := range v.Sent {
+		if sentId <= id {
+			delete(v.Sent, sentId)
+		}
+	}
+	for removedId := range v.Removed {
+		if removedId <= id {
+			delete(v.Removed, removedId)
+		}
+	}
+}
+
+// Prune forgets the snapshots that have left the ring, so that the view of a
+// client that stops acknowledging snapshots does not keep growing
+func (v *ClientView) Prune() {
+	for id := range v.Sent {
+		if _, ok := snapshots.Get(id); !ok {
+			delete(v.Sent, id)
+		}
+	}
+	for id := range v.Removed {
+		if _, ok := snapshots.Get(id); !ok {
+			delete(v.Removed, id)
+		}
+	}
+	for key, id := range v.Known {
+		if _, ok := snapshots.Get(id); !ok {
+			delete(v.Known, key)
+		}
+	}
+}
+
+// KnownSnapshot returns the snapshot holding the state of an element known by
+// the client, if it is still available
+func (v *ClientView) KnownSnapshot(key uint32) (*Snapshot, bool) {
+	id, ok := v.Known[key]
+	if !ok {
+		return nil, false
+	}
+	s, ok := snapshots.Get(id)
+	if !ok {
+		delete(v.Known, key)
+	}
+	return s, ok
+}
+
+// playerKey and entityKey identify the elements of snapshots in client views
+func playerKey(id byte) uint32 {
+	return uint32(id)
+}
+
+func entityKey(netId uint16) uint32 {
+	return 1<<16 | uint32(netId)
+}
+
+// InInterest checks if an element at a given position is relevant to a
+// receiver, from their distance and the visibility volumes of the map
+func InInterest(receiver *Player, x, y, z float32) bool {
+	if config.InterestRadius > 0 &&
+		receiver.distance(x, y, z) > float32(config.InterestRadius) {
+		return false
+	}
+	def := CurrentMapDefinition()
+	from, to := def.VolumeAt(receiver.X, receiver.Y, receiver.Z),
+		def.VolumeAt(x, y, z)
+	if from == nil || to == nil || from == to {
+		return true
+	}
+	for _, name := range from.Visible {
+		if name == to.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// VolumeAt returns the visibility volume containing a point, if any
+func (def *MapDefinition) VolumeAt(x, y, z float32) *VisibilityVolume {
+	for i := range def.Volumes {
+		if def.Volumes[i].Box.Contains(x, y, z) {
+			return &def.Volumes[i]
+		}
+	}
+	return nil
+}
+
+// interestWeight is the priority gained at each tick by an element, higher
+// for close elements
+func interestWeight(receiver *Player, x, y, z float32) float64 {
+	return 1 / (1 + float64(receiver.distance(x, y, z))/InterestFalloff)
+}
+
+// prioritize sorts elements by priority and keeps the most important ones
+// within a byte budget (0 for no limit). At least one element is kept.
+func prioritize(elements []*snapshotElement, budget int) []*snapshotElement {
+	sort.Sort(elementsByPriority(elements))
+	if budget <= 0 {
+		return elements
+	}
+	kept, used := make([]*snapshotElement, 0, len(elements)), 0
+	for _, e := range elements {
+		if used > 0 && used+len(e.Data) > budget {
+			continue
+		}
+		kept = append(kept, e)
+		used += len(e.Data)
+	}
+	return kept
+}
+
+// tickBudget returns the number of bytes that can be sent to a client at each
+// tick (0 for no limit)
+func tickBudget() int {
+	return config.BandwidthBudget * config.Tickrate / 1000
+}
+
+type elementsByPriority []*snapshotElement
+
+func (s elementsByPriority) Len() int           { return len(s) }
+func (s elementsByPriority) Less(i, j int) bool { return s[i].Priority > s[j].Priority }
+func (s elementsByPriority) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInInterest(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.InterestRadius = 100
+	config = &testConfig
+	defer func() {
+		config = &defaultConfig
+	}()
+	currentMap = "test"
+	mapDefinitions = map[string]*MapDefinition{"test": {
+		Name: "test",
+		Volumes: []VisibilityVolume{
+			{Name: "a", Box: Box{Max: [3]float32{10, 10, 10}},
+				Visible: []string{"b"}},
+			{Name: "b", Box: Box{Min: [3]float32{20, 0, 0},
+				Max: [3]float32{30, 10, 10}}},
+			{Name: "c", Box: Box{Min: [3]float32{40, 0, 0},
+				Max: [3]float32{50, 10, 10}}},
+		},
+	}}
+	defer func() {
+		mapDefinitions = make(map[string]*MapDefinition)
+		currentMap = ""
+	}()
+
+	receiver := &Player{}
+	receiver.X, receiver.Y, receiver.Z = 5, 5, 5
+	if !InInterest(receiver, 25, 5, 5) || InInterest(receiver, 45, 5, 5) {
+		t.Log("Visibility volumes are not respected")
+		t.Fail()
+	}
+	if !InInterest(receiver, 5, 5, 90) || InInterest(receiver, 5, 5, 200) {
+		t.Log("The interest radius is not respected")
+		t.Fail()
+	}
+}
+
+func TestSnapshotBudget(t *testing.T) {
+	config = &defaultConfig
+	if err := SetupDeltaEncoders(); err != nil {
+		t.Log("Couldn't set up delta encoders:", err)
+		t.FailNow()
+	}
+	snapshots = NewSnapshotRing(8)
+	receiver := &Player{Account: "a", Initialized: true}
+	near := &Player{Account: "b", Initialized: true}
+	far := &Player{Account: "c", Initialized: true}
+	near.X, far.X = 1, 500
+	players = map[byte]*Player{1: receiver, 2: near, 3: far}
+	defer func() {
+		players = make(map[byte]*Player)
+	}()
+
+	// Only one player fits in the budget
+	s := snapshots.Save()
+	full := len(EncodePlayer([]byte{'A', 2}, &near.PlayerState, nil))
+	receiver.View = NewClientView()
+	for _, key := range []uint32{playerKey(2), playerKey(3)} {
+		receiver.View.Priority[key] = 0
+	}
+	testConfig := defaultConfig
+	testConfig.BandwidthBudget = full * 1000 / testConfig.Tickrate
+	config = &testConfig
+	defer func() {
+		config = &defaultConfig
+	}()
+
+	s.Packet(receiver)
+	if sent := receiver.View.Sent[s.Id]; len(sent) != 1 ||
+		sent[0] != playerKey(2) {
+		t.Log("The nearst player has not been sent first:", sent)
+		t.FailNow()
+	}
+	receiver.Acknowledge(s.Id)
+	if _, ok := receiver.View.Known[playerKey(3)]; ok {
+		t.Log("A player who has not been sent is known by the client")
+		t.Fail()
+	}
+
+	// The far player is sent once its priority is high enough
+	for i := 0; i < 100; i++ {
+		near.X += 1
+		s = snapshots.Save()
+		s.Packet(receiver)
+		for _, key := range receiver.View.Sent[s.Id] {
+			if key == playerKey(3) {
+				return
+			}
+		}
+	}
+	t.Log("The far player has never been sent")
+	t.Fail()
+}
+
+func TestClientViewPrune(t *testing.T) {
+	config = &defaultConfig
+	if err := SetupDeltaEncoders(); err != nil {
+		t.Log("Couldn't set up delta encoders:", err)
+		t.FailNow()
+	}
+	snapshots = NewSnapshotRing(4)
+	receiver := &Player{Account: "a", Initialized: true}
+	other := &Player{Account: "b", Initialized: true}
+	players = map[byte]*Player{1: receiver, 2: other}
+	defer func() {
+		players = make(map[byte]*Player)
+	}()
+
+	s := snapshots.Save()
+	s.Packet(receiver)
+	receiver.Acknowledge(s.Id)
+
+	// A client that stops acknowledging snapshots
+	for i := 0; i < 20; i++ {
+		other.X++
+		snapshots.Save().Packet(receiver)
+	}
+	if len(receiver.View.Sent) > 4 || len(receiver.View.Known) != 0 {
+		t.Log("The view keeps snapshots that left the ring:",
+			len(receiver.View.Sent), len(receiver.View.Known))
+		t.Fail()
+	}
+}
+
+func TestOutOfInterestMarker(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.InterestRadius = 100
+	config = &testConfig
+	defer func() {
+		config = &defaultConfig
+	}()
+	if err := SetupDeltaEncoders(); err != nil {
+		t.Log("Couldn't set up delta encoders:", err)
+		t.FailNow()
+	}
+	snapshots = NewSnapshotRing(8)
+	receiver := &Player{Account: "a", Initialized: true}
+	other := &Player{Account: "b", Initialized: true}
+	players = map[byte]*Player{1: receiver, 2: other}
+	defer func() {
+		players = make(map[byte]*Player)
+	}()
+
+	s := snapshots.Save()
+	s.Packet(receiver)
+	receiver.Acknowledge(s.Id)
+
+	// The client is told to stop drawing the player leaving its interest
+	other.X = 500
+	s = snapshots.Save()
+	marked := false
+	for _, p := range s.Packet(receiver) {
+		if bytes.Contains(p.Data, []byte{'O', 2}) {
+			marked = true
+		}
+	}
+	if !marked {
+		t.Log("No marker has been sent for the player out of interest")
+		t.FailNow()
+	}
+	receiver.Acknowledge(s.Id)
+	if _, ok := receiver.View.Known[playerKey(2)]; ok {
+		t.Log("A player out of interest is still known by the client")
+		t.Fail()
+	}
+	s = snapshots.Save()
+	s.Packet(receiver)
+	if len(receiver.View.Removed) != 0 {
+		t.Log("The marker is sent again once acknowledged")
+		t.Fail()
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Weight of a new sample in the smoothed latency of a player
+	latencySmoothing = 8
+	// Extra room given to hit points to absorb floating point errors and
+	// interpolation approximations
+	hitTolerance = 0.1
+)
+
+// UpdateLatency folds a new round-trip time sample into the player's latency
+func (p *Player) UpdateLatency(sample time.Duration) {
+	if sample < 0 {
+		return
+	}
+	if p.Latency == 0 {
+		p.Latency = sample
+		return
+	}
+	p.Latency += (sample - p.Latency) / latencySmoothing
+}
+
+// RewindTime returns the moment of the world the player was looking at when
+// their last packet has been sent
+func (p *Player) RewindTime() time.Time {
+	rewind := p.Latency
+	maxRewind := time.Duration(config.MaxRewind) * time.Millisecond
+	if rewind > maxRewind {
+		rewind = maxRewind
+	}
+	return time.Now().Add(-rewind)
+}
+
+// RewindPlayer computes the position of a player at a given moment using the
+// world snapshots, interpolating between the two closest ones
+func RewindPlayer(id byte, target *Player, at time.Time) (x, y, z float32,
+	ok bool) {
+	var before, after *SnapshotPlayer
+	var beforeTime, afterTime time.Time
+	snapshots.Each(func(snapshot *Snapshot) {
+		p, exists := snapshot.FindPlayer(id)
+		if !exists || p.Account != target.Account {
+			return
+		}
+		if !snapshot.Time.After(at) {
+			if before == nil || snapshot.Time.After(beforeTime) {
+				before, beforeTime = p, snapshot.Time
+			}
+		} else if after == nil || snapshot.Time.Before(afterTime) {
+			after, afterTime = p, snapshot.Time
+		}
+	})
+
+	switch {
+	case before == nil && after == nil:
+		return 0, 0, 0, false
+	case before == nil:
+		return after.X, after.Y, after.Z, true
+	case after == nil:
+		return before.X, before.Y, before.Z, true
+	}
+
+	ratio := float32(at.Sub(beforeTime)) / float32(afterTime.Sub(beforeTime))
+	x = before.X + (after.X-before.X)*ratio
+	y = before.Y + (after.Y-before.Y)*ratio
+	z = before.Z + (after.Z-before.Z)*ratio
+	return x, y, z, true
+}
+
+// InHitbox checks if a point is inside the hitbox of a player standing at a
+// given position. Hitboxes are boxes centered on the player's position.
+func InHitbox(hitX, hitY, hitZ, x, y, z float32) bool {
+	radius := float32(config.HitboxRadius) + hitTolerance
+	halfHeight := float32(config.HitboxHeight)/2 + hitTolerance
+	return abs32(hitX-x) <= radius &&
+		abs32(hitZ-z) <= radius &&
+		abs32(hitY-y) <= halfHeight
+}
+
+// ValidateHit checks a hit claimed by a player against the positions its
+// victim had when the shooter fired, as seen by the shooter
+func ValidateHit(shooter *Player, victimId byte, hitX, hitY, hitZ float32) bool {
+	victim, ok := players[victimId]
+	if !ok {
+		return false
+	}
+	if !config.LagCompensation || victim.Equals(shooter) {
+		return InHitbox(hitX, hitY, hitZ, victim.X, victim.Y, victim.Z)
+	}
+	x, y, z, ok := RewindPlayer(victimId, victim, shooter.RewindTime())
+	if !ok {
+		// No history available yet: fall back to the current position
+		x, y, z = victim.X, victim.Y, victim.Z
+	}
+	return InHitbox(hitX, hitY, hitZ, x, y, z)
+}
+
+// abs32 returns the absolute value of a float32
+func abs32(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestInHitbox(t *testing.T) {
+	config = &defaultConfig
+	if !InHitbox(0.5, 0.9, -0.5, 0, 0, 0) {
+		t.Log("A hit inside the hitbox has been rejected")
+		t.Fail()
+	}
+	if InHitbox(2, 0, 0, 0, 0, 0) || InHitbox(0, 2, 0, 0, 0, 0) {
+		t.Log("A hit outside the hitbox has been accepted")
+		t.Fail()
+	}
+}
+
+func TestRewindPlayer(t *testing.T) {
+	snapshots = NewSnapshotRing(10)
+	target := &Player{Account: "target", Initialized: true}
+	players = map[byte]*Player{0: target}
+	for i := 0; i < 3; i++ {
+		target.X = float32(i * 10)
+		snapshots.Save()
+	}
+	// Spread snapshots over time
+	now := time.Now()
+	for i := uint32(0); i < 3; i++ {
+		s, _ := snapshots.Get(i)
+		s.Time = now.Add(time.Duration(i) * time.Second)
+	}
+
+	x, _, _, ok := RewindPlayer(0, target, now.Add(1500*time.Millisecond))
+	if !ok || x != 15 {
+		t.Log("Wrong interpolated position:", x)
+		t.Fail()
+	}
+	if x, _, _, _ := RewindPlayer(0, target, now.Add(-time.Second)); x != 0 {
+		t.Log("Rewinding before the history should use the oldest snapshot")
+		t.Fail()
+	}
+	if _, _, _, ok := RewindPlayer(0, &Player{Account: "other"}, now); ok {
+		t.Log("Another player in the same slot has been matched")
+		t.Fail()
+	}
+	players = make(map[byte]*Player)
+}
+
+func TestHitWithoutImpact(t *testing.T) {
+	previousConfig, previousMode := config, gameMode
+	previousPlayers, previousSnapshots, previousLog := players, snapshots, log
+	testConfig := defaultConfig
+	testConfig.LagCompensation = true
+	config = &testConfig
+	gameMode = NewDeathmatchMode()
+	shooter, victim := newTestPlayer("shooter"), newTestPlayer("victim")
+	players = map[byte]*Player{0: shooter, 1: victim}
+	snapshots = NewSnapshotRing(2)
+	// Rejected hits are logged
+	log = util.InitLogging("test.log")
+	defer func() {
+		config, gameMode = previousConfig, previousMode
+		log.Close()
+		players, snapshots, log = previousPlayers, previousSnapshots,
+			previousLog
+		os.Remove("test.log")
+	}()
+	h := &PacketHandler{Address: shooter.Address, Player: shooter}
+
+	// Legacy packets are still accepted, without rewinding the victim
+	damagePacket := packet.New(packet.PacketTypeUDP, 0x0C)
+	damagePacket.AddFieldBytes(1, 10, 0, 0, 0)
+	HandleDamagePacket(h, damagePacket)
+	if victim.Health != MaxHealth-10 {
+		t.Log("A hit without impact has been rejected:", victim.Health)
+		t.Fail()
+	}
+
+	// Impacts away from the victim are rejected
+	damagePacket = packet.New(packet.PacketTypeUDP, 0x0C)
+	damagePacket.AddFieldBytes(1, 10, 0, 0, 0)
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, []float32{50, 0, 0})
+	damagePacket.AddField(buf.Bytes())
+	HandleDamagePacket(h, damagePacket)
+	if victim.Health != MaxHealth-10 {
+		t.Log("A hit away from the victim has been accepted:", victim.Health)
+		t.Fail()
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultGravity = 9.81
+	DefaultKillY   = -100
+)
+
+var (
+	mapDefinitions = make(map[string]*MapDefinition)
+)
+
+// MapDefinition describes a map, as written in its file in the maps directory
+type MapDefinition struct {
+	Name    string             `json:"name"`
+	Spawns  []SpawnPoint       `json:"spawns"`
+	Bounds  Box                `json:"bounds"`
+	KillY   float32            `json:"kill_y"`
+	Gravity float32            `json:"gravity"`
+	Modes   []string           `json:"modes"`
+	Flags   []FlagPoint        `json:"flags"`
+	Pickups []PickupPoint      `json:"pickups"`
+	Volumes []VisibilityVolume `json:"volumes"`
+	Solids  []Box              `json:"solids"`
+}
+
+// SpawnPoint is a place where players may appear
+type SpawnPoint struct {
+	X        float32 `json:"x"`
+	Y        float32 `json:"y"`
+	Z        float32 `json:"z"`
+	Rotation float32 `json:"rotation"`
+	Team     byte    `json:"team"`
+	Weight   float32 `json:"weight"`
+
+	LastUsed time.Time `json:"-"`
+}
+
+// FlagPoint is the base of the flag of a team, for capture the flag games
+type FlagPoint struct {
+	X    float32 `json:"x"`
+	Y    float32 `json:"y"`
+	Z    float32 `json:"z"`
+	Team byte    `json:"team"`
+}
+
+// Box is an axis-aligned box
+type Box struct {
+	Min [3]float32 `json:"min"`
+	Max [3]float32 `json:"max"`
+}
+
+// Contains checks if a point is inside a box
+func (b *Box) Contains(x, y, z float32) bool {
+	return x >= b.Min[0] && x <= b.Max[0] &&
+		y >= b.Min[1] && y <= b.Max[1] &&
+		z >= b.Min[2] && z <= b.Max[2]
+}
+
+// LoadMaps loads the definitions of the maps used by the server and removes
+// the maps with a missing or invalid definition from the rotation
+func LoadMaps() {
+	validMaps := make([]string, 0)
+	for _, name := range config.Maps {
+		def, err := LoadMapDefinition(name)
+		if os.IsNotExist(err) {
+			log.Error("No definition found for map " + name)
+			continue
+		} else if err != nil {
+			log.Error("Invalid definition for map " + name + ": " +
+				err.Error())
+			continue
+		}
+		mapDefinitions[name] = def
+		validMaps = append(validMaps, name)
+	}
+	if len(validMaps) == 0 {
+		log.Panic("No valid map to play on!")
+	}
+	config.Maps = validMaps
+}
+
+// LoadMapDefinition reads the definition of a map from the maps directory
+func LoadMapDefinition(name string) (*MapDefinition, error) {
+	data, err := ioutil.ReadFile(filepath.Join(config.MapsDir, name+".json"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseMapDefinition(name, data)
+}
+
+// ParseMapDefinition decodes and validates a map definition
+func ParseMapDefinition(name string, data []byte) (*MapDefinition, error) {
+	def := DefaultMapDefinition(name)
+	def.Spawns = nil
+	if err := json.Unmarshal(data, def); err != nil {
+		return nil, err
+	}
+	if def.Name != name {
+		return nil, errors.New("map name does not match its file name")
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// Validate checks the consistency of a map definition
+func (def *MapDefinition) Validate() error {
+	for i := 0; i < 3; i++ {
+		if def.Bounds.Min[i] >= def.Bounds.Max[i] {
+			return errors.New("empty world bounds")
+		}
+	}
+	if len(def.Spawns) == 0 {
+		return errors.New("no spawn point")
+	}
+	for i := range def.Spawns {
+		spawn := &def.Spawns[i]
+		if !def.Bounds.Contains(spawn.X, spawn.Y, spawn.Z) {
+			return errors.New("spawn point " + strconv.Itoa(i) +
+				" is out of the world bounds")
+		}
+		if spawn.Weight < 0 {
+			return errors.New("spawn point " + strconv.Itoa(i) +
+				" has a negative weight")
+		} else if spawn.Weight == 0 {
+			spawn.Weight = 1
+		}
+	}
+	for i, solid := range def.Solids {
+		for j := 0; j < 3; j++ {
+			if solid.Min[j] >= solid.Max[j] {
+				return errors.New("solid " + strconv.Itoa(i) + " is empty")
+			}
+		}
+		for j, spawn := range def.Spawns {
+			if solid.Contains(spawn.X, spawn.Y, spawn.Z) {
+				return errors.New("spawn point " + strconv.Itoa(j) +
+					" is inside solid " + strconv.Itoa(i))
+			}
+		}
+	}
+	teamFlags := make(map[byte]bool)
+	for i, flag := range def.Flags {
+		if !def.Bounds.Contains(flag.X, flag.Y, flag.Z) {
+			return errors.New("flag " + strconv.Itoa(i) +
+				" is out of the world bounds")
+		}
+		if flag.Team != TeamRed && flag.Team != TeamBlue {
+			return errors.New("flag " + strconv.Itoa(i) + " has no team")
+		}
+		if teamFlags[flag.Team] {
+			return errors.New("flag " + strconv.Itoa(i) +
+				" belongs to a team that already has a flag")
+		}
+		teamFlags[flag.Team] = true
+	}
+	for i := range def.Pickups {
+		pickup := &def.Pickups[i]
+		if !def.Bounds.Contains(pickup.X, pickup.Y, pickup.Z) {
+			return errors.New("pickup " + strconv.Itoa(i) +
+				" is out of the world bounds")
+		}
+		if err := pickup.Validate(); err != nil {
+			return errors.New("pickup " + strconv.Itoa(i) + ": " + err.Error())
+		}
+	}
+	volumes := make(map[string]bool)
+	for _, volume := range def.Volumes {
+		if volumes[volume.Name] {
+			return errors.New("volume " + volume.Name + " is defined twice")
+		}
+		volumes[volume.Name] = true
+	}
+	for _, volume := range def.Volumes {
+		for _, name := range volume.Visible {
+			if !volumes[name] {
+				return errors.New("volume " + volume.Name +
+					" sees an unknown volume " + name)
+			}
+		}
+	}
+	if def.KillY >= def.Bounds.Max[1] {
+		return errors.New("kill height is above the world")
+	}
+	return nil
+}
+
+// DefaultMapDefinition creates the definition used as a base for map
+// definition files, and for maps which have not been loaded
+func DefaultMapDefinition(name string) *MapDefinition {
+	return &MapDefinition{
+		Name:   name,
+		Spawns: []SpawnPoint{{Y: 1, Weight: 1}},
+		Bounds: Box{
+			Min: [3]float32{-1000, -1000, -1000},
+			Max: [3]float32{1000, 1000, 1000},
+		},
+		KillY:   DefaultKillY,
+		Gravity: DefaultGravity,
+		Modes:   []string{},
+		Flags:   []FlagPoint{},
+		Pickups: []PickupPoint{},
+		Solids:  []Box{},
+	}
+}
+
+// CurrentMapDefinition returns the definition of the map being played
+func CurrentMapDefinition() *MapDefinition {
+	if def, ok := mapDefinitions[currentMap]; ok {
+		return def
+	}
+	return DefaultMapDefinition(currentMap)
+}
+
+// CheckKillY kills players falling below the kill height of the map
+func (p *Player) CheckKillY() {
+	if !config.ServerHealth || !p.Initialized || !p.IsAlive() {
+		return
+	}
+	if p.Y < CurrentMapDefinition().KillY {
+		p.Die(p)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestParseMapDefinition(t *testing.T) {
+	config = &defaultConfig
+	def, err := LoadMapDefinition("d_compound")
+	if err != nil || len(def.Spawns) == 0 || def.Gravity == 0 {
+		t.Log("The bundled map definition is invalid:", err)
+		t.Fail()
+	}
+
+	invalid := map[string]string{
+		"wrong name":    `{"name": "other"}`,
+		"no spawn":      `{"name": "test", "spawns": []}`,
+		"out of bounds": `{"name": "test", "spawns": [{"y": 5000}]}`,
+		"empty bounds": `{"name": "test", "spawns": [{}],
+			"bounds": {"min": [0, 0, 0], "max": [0, 10, 10]}}`,
+		"negative weight": `{"name": "test", "spawns": [{"weight": -1}]}`,
+		"syntax error":    `{"name": "test"`,
+		"flag without team": `{"name": "test", "spawns": [{}],
+			"flags": [{"x": 1}]}`,
+		"two flags for a team": `{"name": "test", "spawns": [{}],
+			"flags": [{"team": 1}, {"x": 1, "team": 1}]}`,
+		"empty solid": `{"name": "test", "spawns": [{"y": 5}],
+			"solids": [{"min": [0, 0, 0], "max": [10, 0, 10]}]}`,
+		"spawn inside a solid": `{"name": "test", "spawns": [{"y": 5}],
+			"solids": [{"min": [-10, 0, -10], "max": [10, 10, 10]}]}`,
+	}
+	for reason, data := range invalid {
+		if _, err := ParseMapDefinition("test", []byte(data)); err == nil {
+			t.Log("Invalid map definition accepted:", reason)
+			t.Fail()
+		}
+	}
+
+	def, err = ParseMapDefinition("test",
+		[]byte(`{"name": "test", "spawns": [{"x": 1}]}`))
+	if err != nil || def.Spawns[0].Weight != 1 || def.Gravity != DefaultGravity {
+		t.Log("Default values have not been applied:", err)
+		t.Fail()
+	}
+}
+
+func TestLoadMaps(t *testing.T) {
+	previousConfig, previousDefinitions := config, mapDefinitions
+	previousLog := log
+	testConfig := defaultConfig
+	testConfig.Maps = []string{"d_missing", "d_compound"}
+	config = &testConfig
+	mapDefinitions = make(map[string]*MapDefinition)
+	// Invalid maps are logged
+	log = util.InitLogging("test.log")
+	defer func() {
+		config, mapDefinitions = previousConfig, previousDefinitions
+		log.Close()
+		log = previousLog
+		os.Remove("test.log")
+	}()
+
+	LoadMaps()
+	if len(config.Maps) != 1 || config.Maps[0] != "d_compound" {
+		t.Log("A map without definition has been kept:", config.Maps)
+		t.Fail()
+	}
+	if _, ok := mapDefinitions["d_missing"]; ok {
+		t.Log("A map without definition has been loaded")
+		t.Fail()
+	}
+}
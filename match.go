@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+// Phases of a match, sent in match phase packets (0x14)
+const (
+	MatchWarmup = byte(iota)
+	MatchLive
+	MatchOvertime
+	MatchPostMatch
+	MatchPaused
+)
+
+var (
+	match = &Match{Ready: make(map[string]bool)}
+)
+
+// Match is the state machine of the match played on the current map: a warmup
+// where nothing counts, the live phase, an optional overtime when the match
+// ends with a tie and the results, before the next map
+type Match struct {
+	Phase      byte
+	PhaseStart time.Time
+	// Phase to go back to at the end of a pause
+	ResumePhase byte
+	PauseStart  time.Time
+	// Accounts of the players ready to start the match
+	Ready map[string]bool
+}
+
+// Begin starts the warmup of a new match, or the match itself if there is no
+// warmup
+func (m *Match) Begin() {
+	m.Ready = make(map[string]bool)
+	if config.WarmupTime == 0 {
+		m.SetPhase(MatchLive)
+		return
+	}
+	m.SetPhase(MatchWarmup)
+}
+
+// SetPhase changes the phase of the match and notifies the players
+func (m *Match) SetPhase(phase byte) {
+	m.Phase = phase
+	m.PhaseStart = time.Now()
+	m.broadcastPhase()
+}
+
+// IsPlaying checks if the match is being played: kills and scores only count
+// when it is
+func (m *Match) IsPlaying() bool {
+	return m.Phase == MatchLive || m.Phase == MatchOvertime
+}
+
+// IsOver checks if the match is over and the server is showing its results
+func (m *Match) IsOver() bool {
+	return m.Phase == MatchPostMatch
+}
+
+// AllowsDamage checks if players can be hurt in the current phase
+func (m *Match) AllowsDamage() bool {
+	return m.Phase != MatchPostMatch && m.Phase != MatchPaused
+}
+
+// Remaining returns the time left in the current phase, or 0 if the phase has
+// no time limit
+func (m *Match) Remaining() time.Duration {
+	var duration time.Duration
+	switch m.Phase {
+	case MatchWarmup:
+		duration = time.Duration(config.WarmupTime) * time.Second
+	case MatchLive:
+		duration = time.Duration(config.TimeLimit) * time.Minute
+	case MatchOvertime:
+		duration = time.Duration(config.Overtime) * time.Second
+	case MatchPostMatch:
+		duration = time.Duration(config.Intermission) * time.Second
+	}
+	if duration == 0 {
+		return 0
+	}
+	if remaining := duration - time.Since(m.PhaseStart); remaining > 0 {
+		return remaining
+	}
+	return time.Nanosecond
+}
+
+// Check moves the match to its next phase when needed
+func (m *Match) Check() {
+	elapsed := time.Since(m.PhaseStart)
+	switch m.Phase {
+	case MatchWarmup:
+		if !m.enoughPlayers() {
+			// The warmup countdown only starts with enough players
+			m.PhaseStart = time.Now()
+			return
+		}
+		if m.allReady() || elapsed >= time.Duration(config.WarmupTime)*
+			time.Second {
+			m.Start()
+		}
+
+	case MatchLive:
+		leader, score := gameMode.Leader()
+		if config.ScoreLimit > 0 && score >= config.ScoreLimit && leader != "" {
+			SendMessage(leader + " has reached the score limit!")
+			m.End()
+			return
+		}
+		if config.TimeLimit == 0 ||
+			elapsed < time.Duration(config.TimeLimit)*time.Minute {
+			return
+		}
+		SendMessage("Time limit reached!")
+		if leader == "" && config.Overtime > 0 {
+			SendMessage("Overtime! The next point wins the match.")
+			m.SetPhase(MatchOvertime)
+			return
+		}
+		m.End()
+
+	case MatchOvertime:
+		if leader, _ := gameMode.Leader(); leader != "" ||
+			elapsed >= time.Duration(config.Overtime)*time.Second {
+			m.End()
+		}
+
+	case MatchPostMatch:
+		if elapsed >= time.Duration(config.Intermission)*time.Second {
+			rotation.Next()
+		}
+	}
+}
+
+// Start ends the warmup: scores are reset and everybody respawns
+func (m *Match) Start() {
+	m.reset()
+	m.SetPhase(MatchLive)
+	SendMessage("The match has started!")
+}
+
+// End ends the match, shows its results and announces the next map
+func (m *Match) End() {
+	m.SetPhase(MatchPostMatch)
+	gameMode.OnRoundEnd()
+	if leader, _ := gameMode.Leader(); leader != "" {
+		SendMessage(leader + " wins the match!")
+	} else {
+		SendMessage("The match ended in a draw!")
+	}
+	SendMessage("Next map: " + rotation.NextMap())
+	broadcastMapChange(MapPhaseIntermission, rotation.NextMap(),
+		time.Duration(config.Intermission)*time.Second)
+}
+
+// Restart goes back to the warmup of the current map
+func (m *Match) Restart() {
+	m.reset()
+	m.Begin()
+	SendMessage("The match has been restarted.")
+}
+
+// Pause pauses the match, or resumes it if it is already paused
+func (m *Match) Pause() bool {
+	if m.Phase == MatchPaused {
+		// Paused time does not count in the phase duration
+		m.Phase = m.ResumePhase
+		m.PhaseStart = m.PhaseStart.Add(time.Since(m.PauseStart))
+		m.broadcastPhase()
+		SendMessage("The match has been resumed.")
+		return false
+	}
+	if m.Phase == MatchPostMatch {
+		return false
+	}
+	m.ResumePhase = m.Phase
+	m.PauseStart = time.Now()
+	m.Phase = MatchPaused
+	m.broadcastPhase()
+	SendMessage("The match has been paused.")
+	return true
+}
+
+// SetReady marks a player as ready, or not ready, to start the match
+func (m *Match) SetReady(p *Player, ready bool) {
+	m.Ready[p.Account] = ready
+	MarkScoreboardDirty()
+	if ready {
+		SendMessage(p.Name + " is ready.")
+	} else {
+		SendMessage(p.Name + " is not ready anymore.")
+	}
+}
+
+// SendPhase sends the phase of the match to a player (0x14)
+func (m *Match) SendPhase(p *Player) {
+	p.Send(m.phasePacket())
+}
+
+// reset clears the scores and restarts the game mode
+func (m *Match) reset() {
+	for _, currentPlayer := range players {
+		currentPlayer.ResetStats()
+	}
+	SetGameMode(ModeForMap(currentMap))
+	for _, currentPlayer := range players {
+		if currentPlayer.Initialized {
+			currentPlayer.PlaceOnMap()
+		}
+	}
+}
+
+// enoughPlayers checks if there is enough players to start the match
+func (m *Match) enoughPlayers() bool {
+	playing, _ := PlayerCounts()
+	return playing >= config.MinPlayers
+}
+
+// allReady checks if all the players are ready to start the match
+func (m *Match) allReady() bool {
+	for _, currentPlayer := range players {
+		if currentPlayer.IsPlaying() && !currentPlayer.IsBot() &&
+			!m.Ready[currentPlayer.Account] {
+			return false
+		}
+	}
+	return true
+}
+
+// phasePacket creates the match phase packet (0x14): [phase][remaining time
+// in ms]
+func (m *Match) phasePacket() *packet.Packet {
+	phasePacket := packet.New(packet.PacketTypeTCP, 0x14)
+	phasePacket.AddFieldBytes(m.Phase)
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian,
+		uint32(m.Remaining()/time.Millisecond))
+	phasePacket.AddField(buf.Bytes())
+	return phasePacket
+}
+
+// broadcastPhase sends the phase of the match to all players
+func (m *Match) broadcastPhase() {
+	phasePacket := m.phasePacket()
+	for _, currentPlayer := range players {
+		currentPlayer.Send(phasePacket)
+	}
+	log.Info("Match phase: " + matchPhaseName(m.Phase))
+}
+
+// matchPhaseName returns the display name of a phase
+func matchPhaseName(phase byte) string {
+	switch phase {
+	case MatchWarmup:
+		return "warmup"
+	case MatchLive:
+		return "live"
+	case MatchOvertime:
+		return "overtime"
+	case MatchPostMatch:
+		return "post-match"
+	case MatchPaused:
+		return "paused"
+	}
+	return strconv.Itoa(int(phase))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestMatchPhases(t *testing.T) {
+	previousConfig, previousMode, previousMap, previousDefinitions := config,
+		gameMode, currentMap, mapDefinitions
+	previousPlayers, previousLog := players, log
+	previousRotation, previousMatch := rotation, match
+	udpNetworkInput := UdpNetworkInput
+	config = &defaultConfig
+	currentMap = "test"
+	mapDefinitions = map[string]*MapDefinition{"test": {
+		Name:   "test",
+		Spawns: []SpawnPoint{{Weight: 1}},
+	}}
+	testPlayers := []*Player{newTestPlayer("a"), newTestPlayer("b")}
+	players = map[byte]*Player{0: testPlayers[0], 1: testPlayers[1]}
+	// Chat messages are logged and sent through the UDP queue
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	SetupGameModes()
+	rotation = &MapRotation{Order: []string{"test"}}
+	match = &Match{}
+	defer func() {
+		config, gameMode, currentMap, mapDefinitions = previousConfig,
+			previousMode, previousMap, previousDefinitions
+		log.Close()
+		players, log = previousPlayers, previousLog
+		rotation, match = previousRotation, previousMatch
+		UdpNetworkInput = udpNetworkInput
+		os.Remove("test.log")
+	}()
+	SetGameMode("ffa")
+	match.Begin()
+	if match.Phase != MatchWarmup {
+		t.Log("The match does not begin with a warmup")
+		t.FailNow()
+	}
+
+	// Kills do not count during the warmup
+	testPlayers[1].Die(testPlayers[0])
+	if testPlayers[0].Score != 0 {
+		t.Log("A kill has been counted during the warmup")
+		t.Fail()
+	}
+
+	match.SetReady(testPlayers[0], true)
+	match.Check()
+	if match.Phase != MatchWarmup {
+		t.Log("The match started before all players were ready")
+		t.FailNow()
+	}
+	match.SetReady(testPlayers[1], true)
+	match.Check()
+	if match.Phase != MatchLive || !testPlayers[1].IsAlive() {
+		t.Log("The match did not start when all players were ready")
+		t.FailNow()
+	}
+
+	// Ties at the end of the time limit lead to an overtime
+	match.PhaseStart = time.Now().Add(-time.Duration(config.TimeLimit) *
+		time.Minute)
+	match.Check()
+	if match.Phase != MatchOvertime {
+		t.Log("No overtime after a tie")
+		t.FailNow()
+	}
+
+	// Pauses do not end the overtime
+	match.Pause()
+	match.Check()
+	if match.Phase != MatchPaused || match.AllowsDamage() {
+		t.Log("The match has not been paused")
+		t.FailNow()
+	}
+	match.Pause()
+
+	testPlayers[1].Die(testPlayers[0])
+	match.Check()
+	if match.Phase != MatchPostMatch || testPlayers[0].Score != 1 {
+		t.Log("The first point of the overtime did not end the match")
+		t.Fail()
+	}
+}
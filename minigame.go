@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+// Trigger types of minigame packets (0x09)
+const (
+	MinigameChallenge = byte(iota)
+	MinigameAccept
+	MinigameDecline
+	MinigameResult
+	MinigameEnd
+)
+
+// States of a minigame session
+const (
+	MinigamePending = byte(iota)
+	MinigameActive
+)
+
+const (
+	// Instance of the main game
+	MainInstance = 0
+)
+
+var (
+	minigames = make([]*MinigameSession, 0)
+)
+
+// MinigameSession is a minigame played by two players, in their own instance
+type MinigameSession struct {
+	Minigame   byte
+	Instance   byte
+	State      byte
+	Challenger *Player
+	Opponent   *Player
+	// Time of the challenge, or of the beginning of the game once accepted
+	Start time.Time
+	// Winners reported by each player, by account
+	Results map[string]*Player
+}
+
+// FindMinigame returns the minigame session a player takes part in, if any
+func FindMinigame(p *Player) *MinigameSession {
+	for _, session := range minigames {
+		if session.Challenger.Equals(p) || session.Opponent.Equals(p) {
+			return session
+		}
+	}
+	return nil
+}
+
+// Challenge creates a minigame session waiting for the opponent to accept it
+func Challenge(challenger, opponent *Player, minigame byte) error {
+	switch {
+	case opponent == nil || !opponent.Initialized:
+		return errors.New("unknown opponent")
+	case challenger.Equals(opponent):
+		return errors.New("cannot challenge oneself")
+	case FindMinigame(challenger) != nil || FindMinigame(opponent) != nil:
+		return errors.New("already in a minigame")
+	}
+	session := &MinigameSession{
+		Minigame:   minigame,
+		State:      MinigamePending,
+		Challenger: challenger,
+		Opponent:   opponent,
+		Start:      time.Now(),
+		Results:    make(map[string]*Player),
+	}
+	minigames = append(minigames, session)
+	session.notify(MinigameChallenge, challenger)
+	return nil
+}
+
+// Accept starts a pending session, when its opponent accepts the challenge
+func (s *MinigameSession) Accept(p *Player) error {
+	if s.State != MinigamePending || !s.Opponent.Equals(p) {
+		return errors.New("no challenge to accept")
+	}
+	instance, err := newInstance()
+	if err != nil {
+		return err
+	}
+	s.State = MinigameActive
+	s.Instance = instance
+	s.Start = time.Now()
+	s.Challenger.Instance = instance
+	s.Opponent.Instance = instance
+	s.notify(MinigameAccept, p)
+	return nil
+}
+
+// Report saves the winner reported by a player. Players admitting their
+// defeat end the game at once, otherwise both players have to agree.
+func (s *MinigameSession) Report(p, winner *Player) error {
+	if s.State != MinigameActive {
+		return errors.New("the minigame has not started")
+	}
+	if winner == nil || (!winner.Equals(s.Challenger) &&
+		!winner.Equals(s.Opponent)) {
+		return errors.New("the winner does not play this minigame")
+	}
+	s.Results[p.Account] = winner
+	other := s.other(p)
+	otherResult, reported := s.Results[other.Account]
+	switch {
+	case winner.Equals(other):
+		s.Finish(winner)
+	case reported && otherResult.Equals(winner):
+		s.Finish(winner)
+	case reported:
+		// Players disagree on the result
+		s.End(p)
+	}
+	return nil
+}
+
+// Finish ends a session with a winner and credits the winner in the main game
+func (s *MinigameSession) Finish(winner *Player) {
+	loser := s.other(winner)
+	s.notify(MinigameResult, winner)
+	s.close()
+	if match.IsPlaying() {
+		winner.Score += byte(config.MinigameScore)
+		MarkScoreboardDirty()
+	}
+	SendMessage(winner.Name + " has beaten " + loser.Name +
+		" in a minigame!")
+}
+
+// End ends a session without result: declined challenges, players leaving,
+// disagreements and timeouts. The player is the one ending the session, nil
+// for the server.
+func (s *MinigameSession) End(p *Player) {
+	trigger := MinigameEnd
+	if s.State == MinigamePending && p != nil {
+		trigger = MinigameDecline
+	}
+	if p == nil {
+		p = s.Challenger
+	}
+	s.notify(trigger, p)
+	s.close()
+}
+
+// CheckMinigames ends the sessions that have not been accepted or finished in
+// time
+func CheckMinigames() {
+	for _, session := range minigames {
+		timeout := time.Duration(config.MinigameTimeout) * time.Second
+		if session.State == MinigamePending {
+			timeout = time.Duration(config.ChallengeTimeout) * time.Second
+		}
+		if time.Since(session.Start) > timeout {
+			session.End(nil)
+			// The list changed
+			return
+		}
+	}
+}
+
+// close removes a session and brings its players back to the main game
+func (s *MinigameSession) close() {
+	for i, session := range minigames {
+		if session == s {
+			minigames = append(minigames[:i], minigames[i+1:]...)
+			break
+		}
+	}
+	if s.State == MinigameActive {
+		s.Challenger.Instance = MainInstance
+		s.Opponent.Instance = MainInstance
+	}
+}
+
+// other returns the other participant of a session
+func (s *MinigameSession) other(p *Player) *Player {
+	if s.Challenger.Equals(p) {
+		return s.Opponent
+	}
+	return s.Challenger
+}
+
+// notify sends a minigame packet (0x09) to both participants:
+// [minigame][trigger][0 for the player who triggered it, 1 for the other
+// one][id of the other player]
+func (s *MinigameSession) notify(trigger byte, origin *Player) {
+	other := s.other(origin)
+	originId, _ := origin.Id()
+	otherId, _ := other.Id()
+
+	originPacket := packet.New(packet.PacketTypeTCP, 0x09)
+	originPacket.AddFieldBytes(s.Minigame, trigger, 0x00, otherId)
+	origin.Send(originPacket)
+	otherPacket := packet.New(packet.PacketTypeTCP, 0x09)
+	otherPacket.AddFieldBytes(s.Minigame, trigger, 0x01, originId)
+	other.Send(otherPacket)
+}
+
+// newInstance finds an instance that is not used by any minigame
+func newInstance() (byte, error) {
+	used := make(map[byte]bool)
+	for _, session := range minigames {
+		used[session.Instance] = true
+	}
+	for instance := byte(1); instance < 0xFF; instance++ {
+		if !used[instance] {
+			return instance, nil
+		}
+	}
+	return 0, errors.New("no instance available")
+}
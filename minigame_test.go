@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestMinigameSession(t *testing.T) {
+	previousConfig, previousPlayers, previousLog := config, players, log
+	udpNetworkInput := UdpNetworkInput
+	config = &defaultConfig
+	a, b, c := newTestPlayer("a"), newTestPlayer("b"), newTestPlayer("c")
+	players = map[byte]*Player{0: a, 1: b, 2: c}
+	// Chat messages are logged and sent through the UDP queue
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	defer func() {
+		log.Close()
+		config, players, log = previousConfig, previousPlayers, previousLog
+		UdpNetworkInput = udpNetworkInput
+		minigames = make([]*MinigameSession, 0)
+		os.Remove("test.log")
+	}()
+
+	if Challenge(a, a, 1) == nil || Challenge(a, nil, 1) == nil {
+		t.Log("Invalid challenges have been accepted")
+		t.Fail()
+	}
+	if err := Challenge(a, b, 1); err != nil {
+		t.Log("Couldn't challenge a player:", err)
+		t.FailNow()
+	}
+	if Challenge(c, b, 1) == nil {
+		t.Log("A player has been challenged twice")
+		t.Fail()
+	}
+	session := FindMinigame(b)
+	if session.Accept(a) == nil {
+		t.Log("The challenger accepted its own challenge")
+		t.Fail()
+	}
+	if err := session.Accept(b); err != nil || a.Instance == MainInstance ||
+		a.Instance != b.Instance {
+		t.Log("The players are not in the instance of the minigame")
+		t.FailNow()
+	}
+
+	// Claiming a victory needs the agreement of the opponent
+	session.Report(a, a)
+	if FindMinigame(a) == nil {
+		t.Log("A player won without the agreement of the opponent")
+		t.Fail()
+	}
+	session.Report(b, a)
+	if FindMinigame(a) != nil || a.Instance != MainInstance ||
+		b.Instance != MainInstance {
+		t.Log("The minigame did not end")
+		t.Fail()
+	}
+
+	// Challenges expire
+	Challenge(a, c, 2)
+	FindMinigame(a).Start = time.Now().Add(-time.Duration(
+		config.ChallengeTimeout+1) * time.Second)
+	CheckMinigames()
+	if FindMinigame(c) != nil {
+		t.Log("The challenge did not expire")
+		t.Fail()
+	}
+}
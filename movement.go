@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+const (
+	MovementPolicyOff     = "off"
+	MovementPolicyWarn    = "warn"
+	MovementPolicyCorrect = "correct"
+	MovementPolicyKick    = "kick"
+
+	// Network jitter tolerated when measuring time between two updates
+	movementSlack = 100 * time.Millisecond
+	// Time given to a client to apply a position sent by the server
+	movementGrace = 500 * time.Millisecond
+)
+
+// MovementState is the state sent by a client in a movement packet (0x05)
+type MovementState struct {
+	X, Y, Z                            float32
+	XRotation, YRotation               float32
+	XVelocity, YVelocity, ZVelocity    float32
+	AngularVelocityX, AngularVelocityY float32
+}
+
+// ReadMovementState parses the 40 bytes of a movement packet
+func ReadMovementState(data []byte) (*MovementState, error) {
+	state := new(MovementState)
+	err := binary.Read(bytes.NewReader(data), binary.LittleEndian, state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// CheckMovement compares a movement to the previous state of a player and
+// returns the reason why it is invalid, or an empty string
+func CheckMovement(p *Player, s *MovementState, elapsed time.Duration) string {
+	for _, f := range []float32{s.X, s.Y, s.Z, s.XVelocity, s.YVelocity,
+		s.ZVelocity} {
+		if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+			return "invalid values"
+		}
+	}
+
+	// First movement since the player has spawned
+	if p.LastMovement.IsZero() {
+		return ""
+	}
+
+	seconds := (elapsed + movementSlack).Seconds()
+	dx, dy, dz := float64(s.X-p.MovementOrigin[0]),
+		float64(s.Y-p.MovementOrigin[1]), float64(s.Z-p.MovementOrigin[2])
+
+	if math.Sqrt(dx*dx+dy*dy+dz*dz) > config.MaxPositionChange {
+		return "teleport"
+	}
+	// Vertical moves are left apart since falls may be very fast
+	if math.Hypot(dx, dz) > config.MaxSpeed*seconds {
+		return "speed"
+	}
+	if math.Hypot(float64(s.XVelocity), float64(s.ZVelocity)) >
+		config.MaxSpeed {
+		return "velocity"
+	}
+	dvx, dvz := float64(s.XVelocity-p.XVelocity),
+		float64(s.ZVelocity-p.ZVelocity)
+	if math.Hypot(dvx, dvz) > config.MaxAcceleration*seconds {
+		return "acceleration"
+	}
+	return ""
+}
+
+// ApplyMovement validates a movement sent by a client and applies it to the
+// player according to the movement policy of the server
+func (p *Player) ApplyMovement(s *MovementState) {
+	now := time.Now()
+	if config.MovementPolicy != MovementPolicyOff {
+		reason := CheckMovement(p, s, now.Sub(p.LastMovement))
+		if reason != "" && now.Before(p.MovementGrace) {
+			// Movement sent before the client applied the server position
+			return
+		} else if reason != "" && !p.MovementViolation(reason) {
+			return
+		} else if reason == "" && p.MovementViolations > 0 {
+			p.MovementViolations--
+		}
+	}
+
+	p.X, p.Y, p.Z = s.X, s.Y, s.Z
+	p.XRotation, p.YRotation = s.XRotation, s.YRotation
+	p.XVelocity, p.YVelocity, p.ZVelocity = s.XVelocity, s.YVelocity,
+		s.ZVelocity
+	p.AngularVelocityX, p.AngularVelocityY = s.AngularVelocityX,
+		s.AngularVelocityY
+	p.MovementOrigin = [3]float32{s.X, s.Y, s.Z}
+	p.LastMovement = now
+	p.LastUpdate = now
+}
+
+// MovementViolation applies the movement policy to an invalid movement.
+// It returns whether or not the movement should be applied anyway.
+func (p *Player) MovementViolation(reason string) bool {
+	p.MovementViolations++
+	switch config.MovementPolicy {
+	case MovementPolicyWarn:
+		log.Warn("Invalid movement (" + reason + ") from " + p.Name)
+		return true
+	case MovementPolicyKick:
+		if p.MovementViolations >= config.MaxMovementViolations {
+			log.Warn(p.Name + " has been kicked for invalid movements (" +
+				strconv.Itoa(p.MovementViolations) + " violations)")
+			p.Kick("Invalid movements")
+			return false
+		}
+	}
+	log.Debug("Corrected movement (" + reason + ") from " + p.Name)
+	p.SendPosition()
+	return false
+}
+
+// SendPosition forces the client of a player to use the position and velocity
+// known by the server (0x10)
+func (p *Player) SendPosition() {
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, []float32{
+		p.X, p.Y, p.Z, p.XVelocity, p.YVelocity, p.ZVelocity,
+	})
+	positionPacket := packet.New(packet.PacketTypeUDP, 0x10)
+	positionPacket.AddField(buf.Bytes())
+	p.Send(positionPacket)
+	p.MovementGrace = time.Now().Add(p.Latency + movementGrace)
+}
+
+// Teleport moves a player to a given position, stopping all movements
+func (p *Player) Teleport(x, y, z float32) {
+	p.SetPosition(x, y, z)
+	p.SendPosition()
+}
+
+// SetPosition changes the position of a player on the server side only,
+// stopping all movements
+func (p *Player) SetPosition(x, y, z float32) {
+	p.X, p.Y, p.Z = x, y, z
+	p.XVelocity, p.YVelocity, p.ZVelocity = 0, 0, 0
+	p.MovementOrigin = [3]float32{x, y, z}
+	p.LastMovement = time.Now()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckMovement(t *testing.T) {
+	config = &defaultConfig
+	p := &Player{LastMovement: time.Now()}
+
+	// Walking at a reasonable speed
+	s := &MovementState{X: 1, XVelocity: 10}
+	if reason := CheckMovement(p, s, 100*time.Millisecond); reason != "" {
+		t.Log("A valid movement has been rejected:", reason)
+		t.Fail()
+	}
+
+	checks := map[string]*MovementState{
+		"teleport":     {X: 50},
+		"speed":        {X: 8},
+		"velocity":     {XVelocity: 100},
+		"acceleration": {Z: 0.5, ZVelocity: 24},
+	}
+	for expected, s := range checks {
+		if reason := CheckMovement(p, s, 50*time.Millisecond); reason != expected {
+			t.Log("Expected a", expected, "violation, got:", reason)
+			t.Fail()
+		}
+	}
+
+	// No check before the first movement of a player
+	if CheckMovement(&Player{}, &MovementState{X: 50}, 0) != "" {
+		t.Log("The first movement of a player has been rejected")
+		t.Fail()
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/deimosgame/deimos-server/packet"
 )
@@ -26,6 +26,10 @@ func SetupPacketHandlers() {
 	RegisterPacketHandler(0x07, HandleInformationChangePacket)
 	RegisterPacketHandler(0x09, HandleMinigamePacket)
 	RegisterPacketHandler(0x0C, HandleDamagePacket)
+	RegisterPacketHandler(0x15, HandleScoreboardPacket)
+	RegisterPacketHandler(0x17, HandleInputPacket)
+	RegisterPacketHandler(0x18, HandleFirePacket)
+	RegisterPacketHandler(0x19, HandleFollowPacket)
 
 	// Bouncing packets
 	RegisterPacketHandler(0x08, HandleBounce(packet.PacketTypeUDP))
@@ -115,12 +119,13 @@ func (h *PacketHandler) GetPlayer() (*Player, error) {
 	return nil, errors.New("Unknown player")
 }
 
-// HandleBounce manages bouncing packets to all players, except the sender
+// HandleBounce manages bouncing packets to the players who can see the sender
 func HandleBounce(packetType byte) func(h *PacketHandler, p *packet.Packet) {
 	return func(h *PacketHandler, p *packet.Packet) {
 		p.Type = packetType
 		for _, currentPlayer := range players {
-			if currentPlayer.Equals(h.Player) {
+			if currentPlayer.Equals(h.Player) ||
+				!currentPlayer.CanSee(h.Player) {
 				continue
 			}
 			currentPlayer.Send(p)
@@ -151,7 +156,11 @@ func HandleClientConnectionPacket(h *PacketHandler, p *packet.Packet) {
 		h.Error()
 		return
 	}
-	// Check if the account is not already used
+	// Check if the account is not already used, or reserved for bots
+	if strings.HasPrefix(userId, BotAccountPrefix) {
+		h.Error()
+		return
+	}
 	for _, player := range players {
 		if player.Account == userId {
 			h.Error()
@@ -178,12 +187,25 @@ func HandleClientConnectionPacket(h *PacketHandler, p *packet.Packet) {
 		return
 	}
 
+	// Players may ask to spectate with a byte after their token, and become
+	// spectators when the game is full
+	flagIndex := len(userId) + len(token) + 2
+	spectator := len(p.Data) > flagIndex && p.Data[flagIndex] == 1
+	if !spectator && !CanJoin() {
+		spectator = true
+	}
+	if spectator && !CanSpectate() {
+		outPacket.AddFieldBytes(0)
+		h.Answer(outPacket)
+		return
+	}
+
 	// Modify the player previously created during the handsake
 	player := h.Player
+	player.Spectating = spectator
 	player.Account = userId
-	player.LastAcknowledged = &World{}
+	player.View = NewClientView()
 	player.Initialized = true
-	player.Y = 1
 	CheckUnlockedAchivements(player)
 	player.RefreshName()
 
@@ -194,8 +216,15 @@ func HandleClientConnectionPacket(h *PacketHandler, p *packet.Packet) {
 	outPacket.AddFieldBytes(1)
 	outPacket.AddFieldString(currentMap)
 	h.Answer(outPacket)
+	match.SendPhase(player)
+	if !spectator {
+		gameMode.OnJoin(player)
+	}
+	player.PlaceOnMap()
+	player.SendFollow()
 
 	UpdatePlayerList()
+	SendEntities(player)
 
 	log.Info(player.Name + " (" + player.Account + " - " +
 		(*h.Address.TCPAddr).String() + ") has joined the game!")
@@ -255,9 +284,18 @@ func HandleChatPacket(h *PacketHandler, p *packet.Packet) {
 			currentPlayer.SendMessage("<PM " + player.Name + "> " + messageText)
 		}
 		return
+	} else if message[0] == '#' {
+		// Handle team messages
+		if player.Team == TeamNone {
+			player.SendMessage("You are not in a team.")
+			return
+		}
+		SendTeamMessage(player, "<["+TeamName(player.Team)+"] "+
+			player.Name+"> "+message[1:])
+		return
 	}
 
-	SendMessage("<" + player.Name + "> " + message)
+	SendInstanceMessage(player, "<"+player.Name+"> "+message)
 }
 
 // HandleAcknowledgementPacket (0x04) handles world acknowledgement packets from
@@ -270,59 +308,33 @@ func HandleAcknowledgementPacket(h *PacketHandler, p *packet.Packet) {
 		return
 	}
 	id := binary.LittleEndian.Uint32(idBytes)
-	if snapshot, ok := worldSnapshots[id]; ok {
-		h.Player.LastAcknowledged = snapshot
-	} else {
-		h.Error()
-		return
+	if !h.Player.Acknowledge(id) {
+		// The snapshot is too old: the player keeps the states it
+		// acknowledged before, or gets full states until a newer snapshot is
+		// acknowledged
+		log.Debug("Acknowledgement of an unavailable snapshot from " +
+			h.Player.Name)
 	}
 }
 
 // HandleMovementPacket (0x05) changes the position of the player
 func HandleMovementPacket(h *PacketHandler, p *packet.Packet) {
 	player := h.Player
+	if player.InputMode {
+		// The position of players sending their inputs is computed by the
+		// server
+		return
+	}
 	if len(p.Data) != 40 {
 		h.Error()
 		return
 	}
-	// Getting variables out of the packet
-	for i := 0; i < 40; i += 4 {
-		field, err := p.GetField(i, 4)
-		if err != nil {
-			h.Error()
-			return
-		}
-		var element float32
-		buf := bytes.NewReader(field)
-		err = binary.Read(buf, binary.LittleEndian, &element)
-		if err != nil {
-			h.Error()
-			return
-		}
-		switch i {
-		case 0:
-			player.X = element
-		case 4:
-			player.Y = element
-		case 8:
-			player.Z = element
-		case 12:
-			player.XRotation = element
-		case 16:
-			player.YRotation = element
-		case 20:
-			player.XVelocity = element
-		case 24:
-			player.YVelocity = element
-		case 28:
-			player.ZVelocity = element
-		case 32:
-			player.AngularVelocityX = element
-		case 36:
-			player.AngularVelocityY = element
-		}
+	state, err := ReadMovementState(p.Data)
+	if err != nil {
+		h.Error()
+		return
 	}
-	player.LastUpdate = time.Now()
+	player.ApplyMovement(state)
 }
 
 // HandleInformationChangePacket (0x07) is a packet for small information
@@ -355,7 +367,9 @@ func HandleInformationChangePacket(h *PacketHandler, p *packet.Packet) {
 		return
 	}
 	// Update player
-	player.CurrentWeapon = weapon[0]
+	if _, ok := weapons[weapon[0]]; ok || len(weapons) == 0 {
+		player.CurrentWeapon = weapon[0]
+	}
 	player.ModelId = model[0]
 
 	if player.CurrentWeapon == 5 {
@@ -371,98 +385,65 @@ func HandleInformationChangePacket(h *PacketHandler, p *packet.Packet) {
 		return
 	}
 
-	// Happens when the player dies
-	player.LifeState = lifeState[0]
-	if lifeState[0] != 0 {
+	// The server decides of deaths by itself: only suicides reported by the
+	// client are accepted (kill command, falling out of the map, ...)
+	if config.ServerHealth {
+		if lifeState[0] == LifeStateDead {
+			player.Die(player)
+		}
 		return
 	}
 
-	if player.LastDamage == nil || player.LastDamage.Player.Equals(player) {
-		log.Infof("%s died.", player.Name)
-	} else {
-		log.Infof("%s was killed by %s.", player.Name,
-			player.LastDamage.Player.Name)
-	}
-
-	if !player.LastDamage.Player.Equals(player) && player.CurrentStreak > 5 {
-		// Achievement: Instant cooling
-		UnlockAchievement(player.LastDamage.Player, 12)
+	// Legacy path: happens when the player dies
+	player.LifeState = lifeState[0]
+	if lifeState[0] != LifeStateDead {
+		return
 	}
 
-	// Kill packet, for Manu
-	killPacket := packet.New(packet.PacketTypeTCP, 0x0D)
-	victimId, authorId := byte(0), byte(0)
-	for i, currentPlayer := range players {
-		if currentPlayer.Equals(player) {
-			victimId = i
-		}
-		if currentPlayer.Equals(player.LastDamage.Player) {
-			authorId = i
-		}
+	killer := player
+	if player.LastDamage != nil {
+		killer = player.LastDamage.Player
 	}
-	killPacket.AddFieldBytes(victimId)
-	killPacket.AddFieldBytes(authorId)
-	if player.LastDamage.Player.Equals(player) {
-		killPacket.AddFieldBytes(0xFF, 0x00)
+	if killer.Equals(player) {
+		log.Infof("%s died.", player.Name)
 	} else {
-		killPacket.AddFieldBytes(player.LastDamage.Player.CurrentWeapon)
-		if player.LastDamage.Player.CurrentStreak > 5 {
-			killPacket.AddFieldBytes(0x01)
-		} else {
-			killPacket.AddFieldBytes(0x00)
-		}
-	}
-	for _, currentPlayer := range players {
-		currentPlayer.Send(killPacket)
+		log.Infof("%s was killed by %s.", player.Name, killer.Name)
 	}
-
-	player.CurrentStreak = 0
-	OnPlayerKill(h.Player, player.LastDamage.Player)
+	player.Deaths++
+	BroadcastKill(player, killer)
 }
 
-// HandleMinigamePacket (0x09) manages incoming minigame "requests"
+// HandleMinigamePacket (0x09) manages minigame sessions: [minigame][trigger]
+// [id of the other player, or of the winner for results]
 func HandleMinigamePacket(h *PacketHandler, p *packet.Packet) {
 	if len(p.Data) != 3 {
 		h.Error()
 		return
 	}
-	incomingId := byte(0)
-	for i, currentPlayer := range players {
-		if currentPlayer.Equals(h.Player) {
-			incomingId = i
-			break
-		}
+	minigameId, triggerType, playerId := p.Data[0], p.Data[1], p.Data[2]
+	target := players[playerId]
+
+	var err error
+	session := FindMinigame(h.Player)
+	switch {
+	case triggerType == MinigameChallenge:
+		err = Challenge(h.Player, target, minigameId)
+	case session == nil || session.Minigame != minigameId:
+		err = errors.New("not in this minigame")
+	case triggerType == MinigameAccept:
+		err = session.Accept(h.Player)
+	case triggerType == MinigameResult:
+		err = session.Report(h.Player, target)
+	case triggerType == MinigameDecline || triggerType == MinigameEnd:
+		session.End(h.Player)
+	default:
+		err = errors.New("unknown trigger type")
 	}
-	outgoingIdBytes, err := p.GetField(2, 1)
 	if err != nil {
+		log.Debug("Invalid minigame packet from " + h.Player.Name + ": " +
+			err.Error())
 		h.Error()
-		return
 	}
-	outgoingId := outgoingIdBytes[0]
-	minigameIdBytes, err := p.GetField(0, 1)
-	if err != nil {
-		h.Error()
-		return
-	}
-	minigameId := minigameIdBytes[0]
-	triggerTypeBytes, err := p.GetField(1, 1)
-	if err != nil {
-		h.Error()
-		return
-	}
-	triggerType := triggerTypeBytes[0]
-
-	h.Player.Instance = minigameId
-	players[incomingId].Instance = minigameId
-
-	// Packet for the player who sent the 0x09 packet in the first place
-	incomingPacket := packet.New(packet.PacketTypeTCP, 0x09)
-	incomingPacket.AddFieldBytes(minigameId, triggerType, 0x00, outgoingId)
-	h.Answer(incomingPacket)
-	// Same packet, for his enemy
-	outgoingPacket := packet.New(packet.PacketTypeTCP, 0x09)
-	outgoingPacket.AddFieldBytes(minigameId, triggerType, 0x01, incomingId)
-	players[outgoingId].Send(outgoingPacket)
 }
 
 // HandleDamagePacket (0x0C) handles player damage, may it be from the player himself
@@ -484,27 +465,103 @@ func HandleDamagePacket(h *PacketHandler, p *packet.Packet) {
 		return
 	}
 
+	damageFieldBytes, err := p.GetField(1, 4)
+	if err != nil {
+		h.Error()
+		return
+	}
+	var damage int32
+	buf := bytes.NewReader(damageFieldBytes)
+	binary.Read(buf, binary.LittleEndian, &damage)
+
+	// Hit validation, when the client sends the position of the impact.
+	// Legacy packets without it are only checked against the weapon table.
+	hit := [3]float32{hitPlayer.X, hitPlayer.Y, hitPlayer.Z}
+	if len(p.Data) >= 17 {
+		hitBytes, err := p.GetField(5, 12)
+		if err != nil {
+			h.Error()
+			return
+		}
+		binary.Read(bytes.NewReader(hitBytes), binary.LittleEndian, &hit)
+		if !ValidateHit(h.Player, hitPlayerBytes[0], hit[0], hit[1], hit[2]) {
+			log.Debugf("Rejected a hit from %s on %s", h.Player.Name,
+				hitPlayer.Name)
+			return
+		}
+	}
+
 	if hitPlayer.Equals(h.Player) {
 		// Achievement: Self-Harm
 		UnlockAchievement(h.Player, 7)
+	} else if !ValidateWeaponDamage(h.Player, int(damage),
+		h.Player.distance(hit[0], hit[1], hit[2])) {
+		// Self damage (falls, ...) does not come from weapons
+		log.Debugf("Rejected %s damage from %s with weapon %s",
+			strconv.Itoa(int(damage)), h.Player.Name,
+			strconv.Itoa(int(h.Player.CurrentWeapon)))
+		return
 	}
+	InflictDamage(h.Player, hitPlayer, int(damage))
+}
 
-	// Broadcast the damage packet to all players
-	damagePacket := packet.New(packet.PacketTypeTCP, 0x0C)
-	damageFieldBytes, err := p.GetField(1, 4)
+// HandleInputPacket (0x17) queues the input commands of a player, who is then
+// moved by the server instead of sending movement packets
+func HandleInputPacket(h *PacketHandler, p *packet.Packet) {
+	if !config.InputMovement {
+		h.Error()
+		return
+	}
+	commands, err := ReadInputCommands(p.Data)
 	if err != nil {
+		log.Debug("Invalid input packet from " + h.Player.Name + ": " +
+			err.Error())
 		h.Error()
 		return
 	}
-	damagePacket.AddField(damageFieldBytes)
-	hitPlayer.Send(damagePacket)
+	h.Player.QueueInputs(commands)
+}
 
-	// Save the damage
-	var damage int
-	buf := bytes.NewReader(damageFieldBytes)
-	binary.Read(buf, binary.LittleEndian, &damage)
-	hitPlayer.LastDamage = &DamageData{
-		Player: h.Player,
-		Damage: damage,
+// HandleFirePacket (0x18) fires a projectile simulated by the server:
+// [weapon][origin x, y, z][direction x, y, z]
+func HandleFirePacket(h *PacketHandler, p *packet.Packet) {
+	if len(p.Data) != 25 {
+		h.Error()
+		return
+	}
+	var vectors [2][3]float32
+	binary.Read(bytes.NewReader(p.Data[1:]), binary.LittleEndian, &vectors)
+	_, err := FireProjectile(h.Player, p.Data[0], vectors[0], vectors[1])
+	if err != nil {
+		log.Debug("Rejected a shot from " + h.Player.Name + ": " +
+			err.Error())
+	}
+}
+
+// HandleScoreboardPacket (0x15) sends the scoreboard to a player who asks for
+// it
+func HandleScoreboardPacket(h *PacketHandler, p *packet.Packet) {
+	h.Answer(ScoreboardPacket())
+}
+
+// HandleFollowPacket (0x19) makes a spectator follow a player: [player id], or
+// 0xFF for a free camera
+func HandleFollowPacket(h *PacketHandler, p *packet.Packet) {
+	if len(p.Data) != 1 {
+		h.Error()
+		return
+	}
+	var target *Player
+	if p.Data[0] != NoPlayerId {
+		var ok bool
+		if target, ok = players[p.Data[0]]; !ok {
+			h.Error()
+			return
+		}
+	}
+	if err := h.Player.Follow(target); err != nil {
+		log.Debug("Invalid follow packet from " + h.Player.Name + ": " +
+			err.Error())
+		h.Error()
 	}
 }
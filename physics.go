@@ -0,0 +1,144 @@
+package main
+
+import (
+	"time"
+)
+
+var (
+	// Half size of the box used for the collisions of players
+	PlayerExtents = [3]float32{0.4, 1, 0.4}
+)
+
+// PhysicsBody is an axis-aligned box moved by the physics step
+type PhysicsBody struct {
+	Position [3]float32
+	Velocity [3]float32
+	// Half size of the box around its position
+	Extents [3]float32
+	// Whether or not the body ignores gravity
+	Weightless bool
+	// Whether or not the body stands on something since the last step
+	Grounded bool
+}
+
+// Step moves a body during dt seconds, applying the gravity of the map,
+// collisions with its solids and its world bounds
+func (def *MapDefinition) Step(b *PhysicsBody, dt float32) {
+	if !b.Weightless {
+		b.Velocity[1] -= def.Gravity * dt
+	}
+	b.Grounded = false
+
+	// Axes are moved one after the other so bodies slide along solids
+	for axis := 0; axis < 3; axis++ {
+		move := b.Velocity[axis] * dt
+		if move == 0 {
+			continue
+		}
+		target, hit := b.Position[axis]+move, false
+		for i := range def.Solids {
+			solid := &def.Solids[i]
+			if !b.overlaps(solid, axis) {
+				continue
+			}
+			near, far := b.Position[axis]+b.Extents[axis],
+				target+b.Extents[axis]
+			if move > 0 && near <= solid.Min[axis] && far > solid.Min[axis] {
+				target, hit = solid.Min[axis]-b.Extents[axis], true
+				continue
+			}
+			near, far = b.Position[axis]-b.Extents[axis],
+				target-b.Extents[axis]
+			if move < 0 && near >= solid.Max[axis] && far < solid.Max[axis] {
+				target, hit = solid.Max[axis]+b.Extents[axis], true
+			}
+		}
+		b.Position[axis] = target
+		if hit {
+			b.stop(axis)
+		}
+	}
+	def.clampToBounds(b)
+}
+
+// clampToBounds keeps a body inside the world bounds of a map
+func (def *MapDefinition) clampToBounds(b *PhysicsBody) {
+	for axis := 0; axis < 3; axis++ {
+		min := def.Bounds.Min[axis] + b.Extents[axis]
+		max := def.Bounds.Max[axis] - b.Extents[axis]
+		if b.Position[axis] < min {
+			b.Position[axis] = min
+			if b.Velocity[axis] < 0 {
+				b.stop(axis)
+			}
+		} else if b.Position[axis] > max {
+			b.Position[axis] = max
+			if b.Velocity[axis] > 0 {
+				b.stop(axis)
+			}
+		}
+	}
+}
+
+// overlaps checks if a body overlaps a box on the axes other than a given one
+func (b *PhysicsBody) overlaps(box *Box, axis int) bool {
+	for i := 0; i < 3; i++ {
+		if i == axis {
+			continue
+		}
+		if b.Position[i]-b.Extents[i] >= box.Max[i] ||
+			b.Position[i]+b.Extents[i] <= box.Min[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stop cancels the velocity of a body blocked on an axis
+func (b *PhysicsBody) stop(axis int) {
+	if axis == 1 && b.Velocity[1] < 0 {
+		b.Grounded = true
+	}
+	b.Velocity[axis] = 0
+}
+
+// extrapolated checks if the position of a player should be extrapolated by
+// the server, which is the case for a short time after their last update
+func (p *Player) extrapolated() bool {
+	since := time.Since(p.LastUpdate)
+	return since >= time.Millisecond*15 &&
+		since < time.Millisecond*time.Duration(config.ExtrapolationLimit)
+}
+
+// body returns the physics body of a player
+func (p *Player) body() *PhysicsBody {
+	return &PhysicsBody{
+		Position: [3]float32{p.X, p.Y, p.Z},
+		Velocity: [3]float32{p.XVelocity, p.YVelocity, p.ZVelocity},
+		Extents:  PlayerExtents,
+	}
+}
+
+// setBody applies the result of a physics step to a player
+func (p *Player) setBody(b *PhysicsBody) {
+	p.X, p.Y, p.Z = b.Position[0], b.Position[1], b.Position[2]
+	p.XVelocity, p.YVelocity, p.ZVelocity = b.Velocity[0], b.Velocity[1],
+		b.Velocity[2]
+}
+
+// body returns the physics body of an entity
+func (e *Entity) body() *PhysicsBody {
+	return &PhysicsBody{
+		Position:   [3]float32{e.X, e.Y, e.Z},
+		Velocity:   [3]float32{e.XVelocity, e.YVelocity, e.ZVelocity},
+		Extents:    e.Extents,
+		Weightless: e.Weightless,
+	}
+}
+
+// setBody applies the result of a physics step to an entity
+func (e *Entity) setBody(b *PhysicsBody) {
+	e.X, e.Y, e.Z = b.Position[0], b.Position[1], b.Position[2]
+	e.XVelocity, e.YVelocity, e.ZVelocity = b.Velocity[0], b.Velocity[1],
+		b.Velocity[2]
+}
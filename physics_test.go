@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPhysicsStep(t *testing.T) {
+	def := DefaultMapDefinition("test")
+	def.Gravity = 10
+	def.Solids = []Box{
+		{Min: [3]float32{-50, -1, -50}, Max: [3]float32{50, 0, 50}},
+		{Min: [3]float32{10, 0, -50}, Max: [3]float32{10.5, 20, 50}},
+	}
+	near := func(a, b float32) bool {
+		return math.Abs(float64(a-b)) < 1e-4
+	}
+
+	// Free fall
+	b := &PhysicsBody{Position: [3]float32{0, 10, 0},
+		Extents: [3]float32{0.5, 0.5, 0.5}}
+	for i := 0; i < 5; i++ {
+		def.Step(b, 0.1)
+	}
+	if !near(b.Position[1], 8.5) || !near(b.Velocity[1], -5) || b.Grounded {
+		t.Log("Wrong free fall trajectory:", b.Position, b.Velocity)
+		t.Fail()
+	}
+
+	// Landing on the ground
+	for i := 0; i < 50; i++ {
+		def.Step(b, 0.1)
+	}
+	if !near(b.Position[1], 0.5) || b.Velocity[1] != 0 || !b.Grounded {
+		t.Log("The body has not landed on the ground:", b.Position)
+		t.Fail()
+	}
+
+	// Fast bodies don't go through thin walls
+	b.Velocity[0] = 500
+	def.Step(b, 0.1)
+	if !near(b.Position[0], 9.5) || b.Velocity[0] != 0 || !b.Grounded {
+		t.Log("The body has not been stopped by the wall:", b.Position)
+		t.Fail()
+	}
+
+	// Sliding along the wall
+	b.Velocity[2] = 10
+	def.Step(b, 0.1)
+	if !near(b.Position[2], 1) || !near(b.Position[0], 9.5) {
+		t.Log("The body has not slid along the wall:", b.Position)
+		t.Fail()
+	}
+
+	// World bounds
+	b.Velocity[2] = 100000
+	def.Step(b, 0.1)
+	if !near(b.Position[2], 999.5) || b.Velocity[2] != 0 {
+		t.Log("The body has left the world:", b.Position)
+		t.Fail()
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Kinds of pickups, as written in map definitions
+const (
+	PickupHealth = "health"
+	PickupArmor  = "armor"
+	PickupWeapon = "weapon"
+)
+
+const (
+	// Distance under which a player takes a pickup
+	PickupRadius = 1.5
+)
+
+var (
+	pickups        = make([]*Pickup, 0)
+	PickupHandlers = make([]func(*Player, *Pickup), 0)
+
+	// Default amounts and respawn delays (in seconds) of each kind
+	pickupAmounts  = map[string]int{PickupHealth: 25, PickupArmor: 50}
+	pickupRespawns = map[string]int{PickupHealth: 20, PickupArmor: 30,
+		PickupWeapon: 15}
+	// Kind of pickup sent in pickup events
+	pickupKindIds = map[string]byte{PickupHealth: 0, PickupArmor: 1,
+		PickupWeapon: 2}
+)
+
+// PickupPoint is an item placed on a map by its definition
+type PickupPoint struct {
+	Kind   string  `json:"kind"`
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	Z      float32 `json:"z"`
+	Amount int     `json:"amount"`
+	Weapon byte    `json:"weapon"`
+	// Time before the item comes back, in seconds
+	Respawn int `json:"respawn"`
+}
+
+// Pickup is an item of the current map, represented by an entity
+type Pickup struct {
+	PickupPoint
+	Entity      *Entity
+	Available   bool
+	RespawnTime time.Time
+}
+
+// PickupHandler is implemented by game modes reacting to items being taken
+type PickupHandler interface {
+	OnPickup(p *Player, pickup *Pickup)
+}
+
+// RegisterPickupHandler adds a function called every time a player takes an
+// item
+func RegisterPickupHandler(handler func(*Player, *Pickup)) {
+	PickupHandlers = append(PickupHandlers, handler)
+}
+
+// Validate checks the consistency of a pickup and sets its default values
+func (point *PickupPoint) Validate() error {
+	if _, ok := pickupKindIds[point.Kind]; !ok {
+		return errors.New("unknown kind " + point.Kind)
+	}
+	if point.Kind == PickupWeapon && len(weapons) > 0 {
+		if _, ok := weapons[point.Weapon]; !ok {
+			return errors.New("unknown weapon " +
+				strconv.Itoa(int(point.Weapon)))
+		}
+	}
+	if point.Amount < 0 || point.Respawn < 0 {
+		return errors.New("negative amount or respawn delay")
+	}
+	if point.Amount == 0 {
+		point.Amount = pickupAmounts[point.Kind]
+	}
+	if point.Respawn == 0 {
+		point.Respawn = pickupRespawns[point.Kind]
+	}
+	return nil
+}
+
+// SpawnPickups creates the items of the current map
+func SpawnPickups() {
+	pickups = make([]*Pickup, 0)
+	for _, point := range CurrentMapDefinition().Pickups {
+		model := "pickup_" + point.Kind
+		if point.Kind == PickupWeapon {
+			model += "_" + strconv.Itoa(int(point.Weapon))
+		}
+		pickups = append(pickups, &Pickup{
+			PickupPoint: point,
+			Entity:      SpawnEntity(model, point.X, point.Y, point.Z),
+			Available:   true,
+		})
+	}
+}
+
+// CheckPickups respawns items and gives them to the players touching them
+func CheckPickups() {
+	now := time.Now()
+	for _, pickup := range pickups {
+		if !pickup.Available {
+			if now.Before(pickup.RespawnTime) {
+				continue
+			}
+			pickup.Available = true
+			pickup.Entity.Active = 1
+		}
+		for _, currentPlayer := range players {
+			// Pickups belong to the world of the main instance
+			if !currentPlayer.Initialized || !currentPlayer.IsAlive() ||
+				currentPlayer.Instance != MainInstance ||
+				currentPlayer.distance(pickup.X, pickup.Y, pickup.Z) >
+					PickupRadius || !pickup.CanTake(currentPlayer) {
+				continue
+			}
+			pickup.Take(currentPlayer)
+			break
+		}
+	}
+}
+
+// CanTake checks if a player needs an item
+func (pickup *Pickup) CanTake(p *Player) bool {
+	switch pickup.Kind {
+	case PickupHealth:
+		return p.Health < MaxHealth
+	case PickupArmor:
+		return p.Armor < MaxArmor
+	}
+	return true
+}
+
+// Take gives an item to a player and starts its respawn timer
+func (pickup *Pickup) Take(p *Player) {
+	pickup.Available = false
+	pickup.Entity.Active = 0
+	pickup.RespawnTime = time.Now().Add(time.Duration(pickup.Respawn) *
+		time.Second)
+
+	extra := byte(0)
+	switch pickup.Kind {
+	case PickupHealth:
+		health := p.Health
+		p.Health = byte(minInt(int(p.Health)+pickup.Amount, MaxHealth))
+		p.sendDamage(-int(p.Health - health))
+		extra = byte(minInt(pickup.Amount, 255))
+	case PickupArmor:
+		p.Armor = byte(minInt(int(p.Armor)+pickup.Amount, MaxArmor))
+		p.sendDamage(0)
+		extra = byte(minInt(pickup.Amount, 255))
+	case PickupWeapon:
+		// Weapons and ammo are owned by clients, which give the weapon to
+		// their player when they receive the pickup event
+		extra = pickup.Weapon
+	}
+	BroadcastGameEvent(GameEventPickup, pickupKindIds[pickup.Kind], p, extra)
+
+	if handler, ok := gameMode.(PickupHandler); ok {
+		handler.OnPickup(p, pickup)
+	}
+	for _, handler := range PickupHandlers {
+		handler(p, pickup)
+	}
+}
+
+// minInt returns the smallest of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
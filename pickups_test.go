@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestPickups(t *testing.T) {
+	def := &MapDefinition{
+		Name:   "test",
+		Spawns: []SpawnPoint{{Weight: 1}},
+		Pickups: []PickupPoint{
+			{Kind: PickupHealth, X: 10},
+			{Kind: PickupArmor, X: -10, Amount: 200},
+		},
+	}
+	for i := range def.Pickups {
+		if err := def.Pickups[i].Validate(); err != nil {
+			t.Log("Couldn't validate a pickup:", err)
+			t.FailNow()
+		}
+	}
+	previousConfig, previousMap, previousDefinitions := config, currentMap,
+		mapDefinitions
+	previousPlayers, previousEntities := players, entities
+	config = &defaultConfig
+	currentMap = def.Name
+	mapDefinitions = map[string]*MapDefinition{def.Name: def}
+	p := newTestPlayer("a")
+	p.Health = 90
+	players = map[byte]*Player{0: p}
+	entities = make(map[uint16]*Entity)
+	defer func() {
+		config, currentMap, mapDefinitions = previousConfig, previousMap,
+			previousDefinitions
+		players, entities = previousPlayers, previousEntities
+	}()
+
+	taken := 0
+	PickupHandlers = []func(*Player, *Pickup){func(*Player, *Pickup) {
+		taken++
+	}}
+	defer func() {
+		PickupHandlers = make([]func(*Player, *Pickup), 0)
+	}()
+	SpawnPickups()
+	health, armor := pickups[0], pickups[1]
+
+	// Players with full health do not take health packs
+	p.X = 10
+	p.Health = MaxHealth
+	CheckPickups()
+	if !health.Available {
+		t.Log("A health pack has been taken by a player without damage")
+		t.Fail()
+	}
+
+	// Players of other instances do not see the pickups
+	p.Health, p.Instance = 90, 3
+	CheckPickups()
+	if !health.Available {
+		t.Log("A health pack has been taken from another instance")
+		t.Fail()
+	}
+
+	p.Instance = MainInstance
+	CheckPickups()
+	if health.Available || health.Entity.Active != 0 || p.Health != MaxHealth ||
+		taken != 1 {
+		t.Log("The health pack has not been taken:", p.Health)
+		t.Fail()
+	}
+	// Only the health actually gained is sent to the player
+	healed := int32(0)
+	for len(p.TCPNetworkInput) > 0 {
+		if pkt := <-p.TCPNetworkInput; pkt.Id == 0x0C {
+			binary.Read(bytes.NewReader(pkt.Data[:4]), binary.LittleEndian,
+				&healed)
+		}
+	}
+	if healed != -10 {
+		t.Log("Wrong amount of health sent:", healed)
+		t.Fail()
+	}
+
+	p.X = -10
+	CheckPickups()
+	if armor.Available || p.Armor != MaxArmor {
+		t.Log("The armor has not been taken:", p.Armor)
+		t.Fail()
+	}
+
+	health.RespawnTime = time.Now()
+	CheckPickups()
+	if !health.Available || health.Entity.Active != 1 {
+		t.Log("The health pack did not respawn")
+		t.Fail()
+	}
+}
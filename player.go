@@ -18,10 +18,52 @@ type Player struct {
 	Account string
 	Address *Address
 
+	// Replicated values
+	PlayerState
+
+	Victims            int
+	Deaths             int
+	CurrentStreak      int
+	BestStreak         int
+	MultiKill          int
+	LastKill           time.Time
+	Assists            int
+	DamageHistory      []DamageRecord
+	Achievements       []int
+	Godmode            bool
+	LastDamage         *DamageData
+	LastShot           time.Time
+	ShotDamage         int
+	RespawnTime        time.Time
+	SpawnProtection    time.Time
+	LastUpdate         time.Time
+	LastMovement       time.Time
+	MovementOrigin     [3]float32
+	MovementGrace      time.Time
+	MovementViolations int
+	InputMode          bool
+	PendingInputs      []InputCommand
+	InputSequence      uint32
+	Grounded           bool
+	Latency            time.Duration
+	View               *ClientView
+	Bot                *Bot
+	Spectating         bool
+	Following          *Player
+	TCPNetworkInput    chan *packet.Packet
+	Initialized        bool
+}
+
+// PlayerState contains the values of a player sent to other players in world
+// packets
+type PlayerState struct {
 	// Gameplay values
 	LifeState byte `prefix:"A"`
 	Score     byte `prefix:"L"`
 	Instance  byte `prefix:"I"`
+	Team      byte `prefix:"G"`
+	Health    byte `prefix:"H"`
+	Armor     byte `prefix:"K"`
 
 	// Position
 	X float32 `prefix:"X"`
@@ -31,7 +73,7 @@ type Player struct {
 	XRotation float32 `prefix:"P"`
 	YRotation float32 `prefix:"Q"`
 	// Velocity
-	XVelocity        float32 `prefix:"T"`
+	XVelocity        float32 `prefix:"R"`
 	YVelocity        float32 `prefix:"S"`
 	ZVelocity        float32 `prefix:"T"`
 	AngularVelocityX float32 `prefix:"U"`
@@ -40,16 +82,6 @@ type Player struct {
 	// Misc values
 	ModelId       byte `prefix:"M"`
 	CurrentWeapon byte `prefix:"W"`
-
-	Victims          int
-	CurrentStreak    int
-	Achievements     []int
-	Godmode          bool
-	LastDamage       *DamageData
-	LastUpdate       time.Time
-	LastAcknowledged *World
-	TCPNetworkInput  chan *packet.Packet
-	Initialized      bool
 }
 
 type DamageData struct {
@@ -70,7 +102,8 @@ func MatchByUDPAddress(addr *net.UDPAddr) (*Player, error) {
 // MatchByTCPAddress tries to match a TCP address with the player using it
 func MatchByTCPAddress(addr *net.TCPAddr) (*Player, error) {
 	for _, player := range players {
-		if (*player.Address.TCPAddr).String() == (*addr).String() {
+		if player.Address.TCPAddr != nil &&
+			(*player.Address.TCPAddr).String() == (*addr).String() {
 			return player, nil
 		}
 	}
@@ -95,6 +128,16 @@ func (p *Player) Match(name string) bool {
 			name == "*")
 }
 
+// Id returns the id of a player in the player list
+func (p *Player) Id() (byte, bool) {
+	for i, currentPlayer := range players {
+		if currentPlayer.Equals(p) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // Equals checks whether or not a player is another player
 func (p *Player) Equals(p2 *Player) bool {
 	return p.Account == p2.Account
@@ -102,6 +145,9 @@ func (p *Player) Equals(p2 *Player) bool {
 
 // Send send multiple packets to a player
 func (p *Player) Send(packets ...*packet.Packet) {
+	if p.IsBot() {
+		return
+	}
 	for _, pkt := range packets {
 		p.Address.Send(pkt, p)
 	}
@@ -109,18 +155,16 @@ func (p *Player) Send(packets ...*packet.Packet) {
 
 // NextTick updates a player for the next tick (for prediction purposes)
 func (p *Player) NextTick() {
-	if time.Since(p.LastUpdate) < time.Millisecond*15 {
+	if !p.extrapolated() {
 		return
 	}
 
-	p.X = p.X + p.XVelocity*tickRateSecs
-	p.Y = p.Y + p.YVelocity*tickRateSecs
-	p.Z = p.Z + p.ZVelocity*tickRateSecs
+	body := p.body()
+	CurrentMapDefinition().Step(body, tickRateSecs)
+	p.setBody(body)
 
 	p.XRotation = p.XRotation + p.AngularVelocityX*tickRateSecs
 	p.YRotation = p.YRotation + p.AngularVelocityY*tickRateSecs
-
-	p.LastUpdate = time.Now()
 }
 
 // SendMessage sends a message to a single player
@@ -179,10 +223,19 @@ func (p *Player) RefreshName() error {
 
 // Remove remove a player form the server
 func (p *Player) Remove() {
+	if p.Initialized {
+		gameMode.OnLeave(p)
+	}
+	if session := FindMinigame(p); session != nil {
+		session.End(p)
+	}
+	DestroyProjectiles(p)
 	for i, player := range players {
-		if p.Address.Compare(player.Address) {
+		if player == p || p.Address.Compare(player.Address) {
 			// Network channel closing
-			close(player.TCPNetworkInput)
+			if !player.IsBot() {
+				close(player.TCPNetworkInput)
+			}
 			// Player deletion
 			delete(players, i)
 			break
@@ -193,11 +246,13 @@ func (p *Player) Remove() {
 
 // UpdatePlayerList sends the packet 0x06 to make clients update the player list
 func UpdatePlayerList() {
+	MarkScoreboardDirty()
 	buf := bytes.NewBuffer(nil)
 	for i, player := range players {
 		buf.WriteByte(i)
 		buf.Write([]byte(player.Name))
 		buf.WriteByte(0x00)
+		buf.WriteByte(player.Team)
 	}
 	p := packet.New(packet.PacketTypeUDP, 0x06)
 	bufferBytes := buf.Bytes()
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	// Distance between a player and the origin of a shot accepted for
+	// inaccurate positions
+	FireOriginTolerance = 3
+	// Lifetime of projectiles of weapons without range nor fuse
+	MaxProjectileLifetime = 10 * time.Second
+)
+
+var (
+	// Half size of the box used for the collisions of projectiles
+	ProjectileExtents = [3]float32{0.1, 0.1, 0.1}
+
+	projectiles = make(map[uint16]*Projectile)
+)
+
+// Projectile is a shot simulated by the server, exploding when it touches a
+// player, a solid or at the end of its lifetime
+type Projectile struct {
+	Entity  *Entity
+	Owner   *Player
+	Weapon  *Weapon
+	Expires time.Time
+
+	// Velocity after the last tick, to detect impacts
+	velocity [3]float32
+}
+
+// FireProjectile validates a shot of a player and spawns its projectile
+func FireProjectile(p *Player, weaponId byte, origin,
+	direction [3]float32) (*Projectile, error) {
+	weapon, ok := weapons[weaponId]
+	switch {
+	case !ok || !weapon.HasProjectiles():
+		return nil, errors.New("not a projectile weapon")
+	case weaponId != p.CurrentWeapon:
+		return nil, errors.New("not the current weapon")
+	case !p.IsAlive():
+		return nil, errors.New("dead players can't shoot")
+	case p.distance(origin[0], origin[1], origin[2]) > FireOriginTolerance:
+		return nil, errors.New("shot too far from the player")
+	}
+	length := float32(math.Sqrt(float64(direction[0]*direction[0] +
+		direction[1]*direction[1] + direction[2]*direction[2])))
+	if length == 0 || math.IsNaN(float64(length)) ||
+		math.IsInf(float64(length), 0) {
+		return nil, errors.New("invalid direction")
+	}
+	now := time.Now()
+	if now.Sub(p.LastShot) < time.Duration(
+		float64(weapon.FireInterval())*FireRateTolerance) {
+		return nil, errors.New("fire rate exceeded")
+	}
+	p.LastShot = now
+	p.ShotDamage = 0
+	p.EndSpawnProtection()
+
+	lifetime := MaxProjectileLifetime
+	if weapon.Ballistic {
+		lifetime = time.Duration(weapon.Fuse * float64(time.Second))
+	} else if weapon.Range > 0 {
+		lifetime = time.Duration(float64(weapon.Range/weapon.ProjectileSpeed) *
+			float64(time.Second))
+	}
+	e := SpawnEntity(weapon.ProjectileModel, origin[0], origin[1], origin[2])
+	e.Physics = true
+	e.Weightless = !weapon.Ballistic
+	e.Extents = ProjectileExtents
+	speed := weapon.ProjectileSpeed / length
+	e.Update(origin[0], origin[1], origin[2], direction[0]*speed,
+		direction[1]*speed, direction[2]*speed)
+
+	projectile := &Projectile{
+		Entity:   e,
+		Owner:    p,
+		Weapon:   weapon,
+		Expires:  now.Add(lifetime),
+		velocity: [3]float32{e.XVelocity, e.YVelocity, e.ZVelocity},
+	}
+	projectiles[e.NetId] = projectile
+	return projectile, nil
+}
+
+// CheckProjectiles explodes the projectiles touching something, once they
+// have been moved by the world simulation
+func CheckProjectiles() {
+	now := time.Now()
+	for id, projectile := range projectiles {
+		e := projectile.Entity
+		if _, ok := entities[id]; !ok {
+			// Destroyed with the other entities of the map
+			delete(projectiles, id)
+			continue
+		}
+		velocity := [3]float32{e.XVelocity, e.YVelocity, e.ZVelocity}
+		if victim := projectile.touchedPlayer(); victim != nil {
+			projectile.Explode(victim)
+		} else if !projectile.Weapon.Ballistic &&
+			velocity != projectile.velocity {
+			// Stopped by a solid or the world bounds
+			projectile.Explode(nil)
+		} else if now.After(projectile.Expires) {
+			projectile.Explode(nil)
+		} else {
+			projectile.velocity = velocity
+		}
+	}
+}
+
+// touchedPlayer returns the player whose hitbox contains a projectile, other
+// than its owner
+func (pr *Projectile) touchedPlayer() *Player {
+	for _, currentPlayer := range players {
+		if currentPlayer.Equals(pr.Owner) || !pr.reaches(currentPlayer) {
+			continue
+		}
+		if InHitbox(pr.Entity.X, pr.Entity.Y, pr.Entity.Z, currentPlayer.X,
+			currentPlayer.Y, currentPlayer.Z) {
+			return currentPlayer
+		}
+	}
+	return nil
+}
+
+// reaches checks if a projectile may hurt a player
+func (pr *Projectile) reaches(p *Player) bool {
+	return p.Initialized && p.IsAlive() && p.Instance == pr.Owner.Instance
+}
+
+// Explode destroys a projectile and damages the players in its splash radius.
+// The player directly hit, if any, takes the full damage of the weapon.
+func (pr *Projectile) Explode(direct *Player) {
+	e := pr.Entity
+	delete(projectiles, e.NetId)
+	e.Destroy()
+
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, []float32{e.X, e.Y, e.Z})
+	BroadcastGameEvent(GameEventExplosion, pr.Weapon.Id, pr.Owner,
+		buf.Bytes()...)
+
+	for _, currentPlayer := range players {
+		if !pr.reaches(currentPlayer) {
+			continue
+		}
+		damage := pr.Weapon.SplashDamage(currentPlayer.distance(e.X, e.Y, e.Z))
+		if direct != nil && currentPlayer.Equals(direct) {
+			damage = pr.Weapon.Damage
+		}
+		if damage > 0 {
+			InflictDamage(pr.Owner, currentPlayer, damage)
+		}
+	}
+}
+
+// DestroyProjectiles removes the projectiles fired by a player
+func DestroyProjectiles(p *Player) {
+	for id, projectile := range projectiles {
+		if projectile.Owner.Equals(p) {
+			delete(projectiles, id)
+			projectile.Entity.Destroy()
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProjectiles(t *testing.T) {
+	previousConfig, previousMode, previousMap, previousDefinitions := config,
+		gameMode, currentMap, mapDefinitions
+	previousPlayers, previousEntities := players, entities
+	config = &defaultConfig
+	gameMode = NewDeathmatchMode()
+	currentMap = "test"
+	mapDefinitions = map[string]*MapDefinition{
+		"test": DefaultMapDefinition("test")}
+	shooter, victim, bystander := newTestPlayer("shooter"),
+		newTestPlayer("victim"), newTestPlayer("bystander")
+	victim.X, bystander.X = 10, 12
+	players = map[byte]*Player{0: shooter, 1: victim, 2: bystander}
+	entities = make(map[uint16]*Entity)
+	defer func() {
+		config, gameMode, currentMap, mapDefinitions = previousConfig,
+			previousMode, previousMap, previousDefinitions
+		players, entities = previousPlayers, previousEntities
+		projectiles = make(map[uint16]*Projectile)
+	}()
+	table, err := ParseWeapons([]byte(`[
+		{"id": 6, "damage": 40, "fire_rate": 1, "falloff_min": 0.2,
+			"projectile_speed": 40, "splash_radius": 5},
+		{"id": 7, "damage": 40, "fire_rate": 1, "projectile_speed": 20,
+			"ballistic": true, "fuse": 2, "splash_radius": 5}]`))
+	if err != nil {
+		t.Log("Couldn't parse the weapon table:", err)
+		t.FailNow()
+	}
+	weapons = table
+	defer func() {
+		weapons = make(map[byte]*Weapon)
+	}()
+	origin, direction := [3]float32{0, 0, 0}, [3]float32{2, 0, 0}
+
+	// Direct hits do the full damage and splash damages the players nearby
+	shooter.CurrentWeapon = 6
+	rocket, err := FireProjectile(shooter, 6, origin, direction)
+	if err != nil || rocket.Entity.XVelocity != 40 {
+		t.Log("Couldn't fire a rocket:", err)
+		t.FailNow()
+	}
+	if _, err := FireProjectile(shooter, 6, origin, direction); err == nil {
+		t.Log("The fire rate of the weapon has not been enforced")
+		t.Fail()
+	}
+	if ValidateWeaponDamage(shooter, 10, 10) {
+		t.Log("Damage of a projectile weapon sent by a client was accepted")
+		t.Fail()
+	}
+	InflictDamage(shooter, victim, 10)
+	shooter.Instance = 3
+	InflictDamage(shooter, victim, 10)
+	shooter.Instance = MainInstance
+	if victim.Health != MaxHealth-10 {
+		t.Log("Damage across instances has been applied:", victim.Health)
+		t.FailNow()
+	}
+	victim.Health = MaxHealth
+	rocket.Entity.X = 10
+	CheckProjectiles()
+	if victim.Health != MaxHealth-40 || bystander.Health != MaxHealth-28 ||
+		shooter.Health != MaxHealth {
+		t.Log("Wrong explosion damage:", victim.Health, bystander.Health,
+			shooter.Health)
+		t.Fail()
+	}
+	if len(projectiles) != 0 || len(entities) != 0 {
+		t.Log("The rocket has not been destroyed")
+		t.Fail()
+	}
+
+	// Rockets explode when stopped by a solid
+	shooter.LastShot = time.Time{}
+	rocket, _ = FireProjectile(shooter, 6, origin, direction)
+	rocket.Entity.X, rocket.Entity.XVelocity = 4, 0
+	CheckProjectiles()
+	if len(projectiles) != 0 {
+		t.Log("The rocket has not exploded on impact")
+		t.Fail()
+	}
+
+	// Grenades only explode at the end of their fuse
+	shooter.CurrentWeapon, shooter.LastShot = 7, time.Time{}
+	grenade, _ := FireProjectile(shooter, 7, origin, direction)
+	grenade.Entity.X, grenade.Entity.XVelocity = 4, 0
+	CheckProjectiles()
+	if len(projectiles) != 1 {
+		t.Log("The grenade has exploded before the end of its fuse")
+		t.Fail()
+	}
+	grenade.Expires = time.Now()
+	CheckProjectiles()
+	if len(projectiles) != 0 {
+		t.Log("The grenade has not exploded at the end of its fuse")
+		t.Fail()
+	}
+}
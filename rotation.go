@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+const (
+	MapOrderSequential = "sequential"
+	MapOrderShuffle    = "shuffle"
+)
+
+// Phases of the map changing packet (0x13)
+const (
+	MapPhaseIntermission = byte(iota)
+	MapPhaseLoad
+)
+
+var (
+	rotation = &MapRotation{}
+)
+
+// MapRotation keeps track of the maps played on the server
+type MapRotation struct {
+	Order []string
+	Index int
+}
+
+// StartRotation initializes the rotation with the maps of the config and
+// starts the first one
+func StartRotation() {
+	rotation.Order = make([]string, len(config.Maps))
+	copy(rotation.Order, config.Maps)
+	if config.MapOrder == MapOrderShuffle {
+		shuffleMaps(rotation.Order, "")
+	}
+	rotation.Index = 0
+	currentMap = rotation.Order[0]
+	SpawnPickups()
+	SetGameMode(ModeForMap(currentMap))
+	match.Begin()
+}
+
+// NextMap returns the name of the map following the current one
+func (r *MapRotation) NextMap() string {
+	return r.Order[(r.Index+1)%len(r.Order)]
+}
+
+// Next loads the next map of the rotation
+func (r *MapRotation) Next() {
+	r.Index++
+	if r.Index >= len(r.Order) {
+		r.Index = 0
+		if config.MapOrder == MapOrderShuffle {
+			shuffleMaps(r.Order, currentMap)
+		}
+	}
+	ChangeMap(r.Order[r.Index])
+}
+
+// ChangeMap loads a map immediately and places all players on it
+func ChangeMap(name string) {
+	if _, ok := mapDefinitions[name]; !ok {
+		mapDefinitions[name] = DefaultMapDefinition(name)
+	}
+	for i, mapName := range rotation.Order {
+		if mapName == name {
+			rotation.Index = i
+			break
+		}
+	}
+	currentMap = name
+
+	// Entities belong to the previous map
+	for _, e := range entities {
+		e.Destroy()
+	}
+
+	broadcastMapChange(MapPhaseLoad, name, 0)
+	SpawnPickups()
+	SetGameMode(ModeForMap(name))
+	for _, currentPlayer := range players {
+		if !currentPlayer.Initialized {
+			continue
+		}
+		currentPlayer.ResetStats()
+		currentPlayer.PlaceOnMap()
+	}
+	match.Begin()
+	log.Notice("Map changed to " + name)
+	TriggerHeartbeat()
+}
+
+// PlaceOnMap puts a player on a spawn point of the current map. Players who
+// are not allowed to respawn by the game mode wait there, dead.
+func (p *Player) PlaceOnMap() {
+	if config.ServerHealth && p.CanRespawn() {
+		p.Respawn()
+		return
+	}
+	if config.ServerHealth {
+		p.LifeState = LifeStateDead
+		p.RespawnTime = time.Time{}
+	}
+	p.MoveToSpawn()
+	p.SendPosition()
+}
+
+// broadcastMapChange sends the map changing packet (0x13) to all players
+func broadcastMapChange(phase byte, name string, duration time.Duration) {
+	mapPacket := packet.New(packet.PacketTypeTCP, 0x13)
+	mapPacket.AddFieldBytes(phase)
+	mapPacket.AddFieldString(name)
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, uint32(duration/time.Millisecond))
+	mapPacket.AddField(buf.Bytes())
+	for _, currentPlayer := range players {
+		currentPlayer.Send(mapPacket)
+	}
+}
+
+// shuffleMaps shuffles a list of maps, avoiding to start with a given map
+func shuffleMaps(maps []string, avoid string) {
+	for i := len(maps) - 1; i > 0; i-- {
+		j := rand.Intn(i + 1)
+		maps[i], maps[j] = maps[j], maps[i]
+	}
+	if len(maps) > 1 && maps[0] == avoid {
+		maps[0], maps[1] = maps[1], maps[0]
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestShuffleMaps(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		maps := []string{"a", "b", "c"}
+		shuffleMaps(maps, "a")
+		if maps[0] == "a" {
+			t.Log("The map to avoid is the first one after shuffling")
+			t.Fail()
+			return
+		}
+		found := make(map[string]bool)
+		for _, name := range maps {
+			found[name] = true
+		}
+		if len(found) != 3 {
+			t.Log("Maps have been lost while shuffling:", maps)
+			t.Fail()
+			return
+		}
+	}
+}
+
+func TestNextMap(t *testing.T) {
+	r := &MapRotation{Order: []string{"a", "b", "c"}, Index: 2}
+	if r.NextMap() != "a" {
+		t.Log("The rotation does not loop:", r.NextMap())
+		t.Fail()
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+const (
+	// Time after which the scoreboard is sent again to refresh the pings
+	ScoreboardRefresh = 5 * time.Second
+)
+
+// Flags of a player in the scoreboard
+const (
+	ScoreboardFlagOperator = byte(1 << iota)
+	ScoreboardFlagAlive
+	ScoreboardFlagReady
+	ScoreboardFlagSpectator
+)
+
+var (
+	// Columns sent for every game mode
+	ScoreboardColumns = []string{"kills", "deaths", "assists", "score", "ping",
+		"streak", "best_streak"}
+
+	scoreboardDirty = true
+	lastScoreboard  time.Time
+)
+
+// ScoreboardExtension is implemented by game modes adding their own columns
+// to the scoreboard
+type ScoreboardExtension interface {
+	// Columns returns the names of the columns added by the mode
+	Columns() []string
+	// ColumnValues returns the values of these columns for a player
+	ColumnValues(p *Player) []int
+}
+
+// MarkScoreboardDirty schedules the sending of the scoreboard at the next tick
+func MarkScoreboardDirty() {
+	scoreboardDirty = true
+}
+
+// CheckScoreboard sends the scoreboard to everybody when it has changed
+func CheckScoreboard() {
+	if !scoreboardDirty && time.Since(lastScoreboard) < ScoreboardRefresh {
+		return
+	}
+	scoreboardDirty = false
+	lastScoreboard = time.Now()
+	scoreboardPacket := ScoreboardPacket()
+	for _, currentPlayer := range players {
+		if currentPlayer.Initialized {
+			currentPlayer.Send(scoreboardPacket)
+		}
+	}
+}
+
+// ScoreboardPacket creates the scoreboard packet (0x15):
+// [column count][column names\0...] then, for each player:
+// [player id][flags][team][values as int32...]
+func ScoreboardPacket() *packet.Packet {
+	columns := ScoreboardColumns
+	extension, extended := gameMode.(ScoreboardExtension)
+	if extended {
+		columns = append(columns[:len(columns):len(columns)],
+			extension.Columns()...)
+	}
+
+	scoreboardPacket := packet.New(packet.PacketTypeTCP, 0x15)
+	scoreboardPacket.AddFieldBytes(byte(len(columns)))
+	for _, column := range columns {
+		scoreboardPacket.AddFieldString(column)
+	}
+	for i, currentPlayer := range players {
+		if !currentPlayer.Initialized {
+			continue
+		}
+		values := currentPlayer.ScoreboardValues()
+		if extended {
+			values = append(values, extension.ColumnValues(currentPlayer)...)
+		}
+		scoreboardPacket.AddFieldBytes(i, currentPlayer.ScoreboardFlags(),
+			currentPlayer.Team)
+		buf := bytes.NewBuffer(nil)
+		for _, value := range values {
+			binary.Write(buf, binary.LittleEndian, int32(value))
+		}
+		scoreboardPacket.AddField(buf.Bytes())
+	}
+	return scoreboardPacket
+}
+
+// ScoreboardValues returns the values of the standard columns of the
+// scoreboard for a player
+func (p *Player) ScoreboardValues() []int {
+	return []int{p.Victims, p.Deaths, p.Assists, int(p.Score),
+		int(p.Latency / time.Millisecond), p.CurrentStreak, p.BestStreak}
+}
+
+// ScoreboardFlags returns the flags of a player in the scoreboard
+func (p *Player) ScoreboardFlags() byte {
+	flags := byte(0)
+	if p.IsOperator() {
+		flags |= ScoreboardFlagOperator
+	}
+	if p.IsAlive() {
+		flags |= ScoreboardFlagAlive
+	}
+	if match.Ready[p.Account] {
+		flags |= ScoreboardFlagReady
+	}
+	if p.Spectating {
+		flags |= ScoreboardFlagSpectator
+	}
+	return flags
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestScoreboardPacket(t *testing.T) {
+	previousConfig, previousMode, previousPlayers := config, gameMode,
+		players
+	config = &defaultConfig
+	p := newTestPlayer("a")
+	players = map[byte]*Player{0: p}
+	defer func() {
+		config, gameMode, players = previousConfig, previousMode,
+			previousPlayers
+	}()
+	p.Victims, p.Deaths, p.Assists, p.Score = 3, 2, 1, 4
+	p.Latency = 42 * time.Millisecond
+	p.CurrentStreak, p.BestStreak = 5, 6
+
+	mode := NewCaptureTheFlagMode().(*CaptureTheFlagMode)
+	mode.PlayerCaptures["a"] = 7
+	gameMode = mode
+
+	data := ScoreboardPacket().Data
+	if int(data[0]) != len(ScoreboardColumns)+1 {
+		t.Log("The columns of the game mode are missing:", data[0])
+		t.FailNow()
+	}
+	// Skip the names of the columns
+	offset := 1
+	for i := 0; i < int(data[0]); i++ {
+		offset += bytes.IndexByte(data[offset:], 0) + 1
+	}
+	if data[offset] != 0 || data[offset+1] != ScoreboardFlagAlive {
+		t.Log("Wrong player id or flags:", data[offset:offset+2])
+		t.Fail()
+	}
+	values := make([]int32, data[0])
+	binary.Read(bytes.NewReader(data[offset+3:]), binary.LittleEndian, values)
+	expected := []int32{3, 2, 1, 4, 42, 5, 6, 7}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Log("Wrong scoreboard values:", values)
+			t.Fail()
+			break
+		}
+	}
+}
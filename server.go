@@ -26,23 +26,24 @@ var (
 	serverKeepupAlert = false
 	insecureAlert     = false
 
-	UdpNetworkInput = make(chan *UDPOutboundMessage, NetworkChannelSize)
-	APIInput        = make(chan *APIRequest, NetworkChannelSize)
-	tickRateSecs    float32
+	UdpNetworkInput  = make(chan *UDPOutboundMessage, NetworkChannelSize)
+	APIInput         = make(chan *APIRequest, NetworkChannelSize)
+	heartbeatTrigger = make(chan bool, 1)
+	tickRateSecs     float32
 
 	// Game-related variables
-	currentMap      string
-	worldSnapshotId uint32 = 0
-	worldSnapshots         = make(map[uint32]*World)
-	players                = make(map[byte]*Player)
-	entities               = make(map[*Entity]bool)
+	currentMap string
+	snapshots  *SnapshotRing
+	players    = make(map[byte]*Player)
+	entities   = make(map[uint16]*Entity)
 )
 
 func main() {
 	/* Config loading */
 
 	LoadConfig()
-	currentMap = config.Maps[0]
+	snapshots = NewSnapshotRing(int(SnapshotHistory /
+		(time.Millisecond * time.Duration(config.Tickrate))))
 
 	/* Logging engine */
 
@@ -51,6 +52,19 @@ func main() {
 	log.Notice("Deimos server is loading...")
 	//log.DebugMode = true
 
+	/* Map definitions */
+
+	LoadWeapons()
+	LoadMaps()
+	SetupGameModes()
+	StartRotation()
+
+	/* World packet encoders */
+
+	if err := SetupDeltaEncoders(); err != nil {
+		log.Panic("Invalid replicated fields: " + err.Error())
+	}
+
 	/* Server IP resolving */
 
 	ResolveIP()
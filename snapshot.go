@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+const (
+	// Duration of the world history kept by the server
+	SnapshotHistory = 10 * time.Second
+)
+
+// Snapshot is the state of the world at a given tick. It only contains
+// replicated values so that it can be reused once it gets too old.
+type Snapshot struct {
+	Id       uint32
+	Time     time.Time
+	Players  []SnapshotPlayer
+	Entities []SnapshotEntity
+}
+
+// SnapshotPlayer is the state of a player in a snapshot
+type SnapshotPlayer struct {
+	Id      byte
+	Account string
+	PlayerState
+}
+
+// SnapshotEntity is the state of an entity in a snapshot
+type SnapshotEntity struct {
+	NetId uint16
+	UUID  string
+	EntityState
+}
+
+// SnapshotRing is a fixed-size history of snapshots. Once full, the oldest
+// snapshot is overwritten by the newest one.
+type SnapshotRing struct {
+	snapshots []Snapshot
+	next      uint32
+}
+
+// NewSnapshotRing creates a ring able to store a given number of snapshots
+func NewSnapshotRing(size int) *SnapshotRing {
+	if size < 1 {
+		size = 1
+	}
+	return &SnapshotRing{snapshots: make([]Snapshot, size)}
+}
+
+// Save stores the current state of the world as a new snapshot
+func (r *SnapshotRing) Save() *Snapshot {
+	s := &r.snapshots[r.next%uint32(len(r.snapshots))]
+	s.Id = r.next
+	s.Time = time.Now()
+	r.next++
+
+	s.Players = s.Players[:0]
+	for i, p := range players {
+		if !p.IsPlaying() {
+			continue
+		}
+		s.Players = append(s.Players, SnapshotPlayer{
+			Id:          i,
+			Account:     p.Account,
+			PlayerState: p.PlayerState,
+		})
+	}
+	sort.Sort(snapshotPlayers(s.Players))
+
+	s.Entities = s.Entities[:0]
+	for i, e := range entities {
+		s.Entities = append(s.Entities, SnapshotEntity{
+			NetId:       i,
+			UUID:        e.UUID,
+			EntityState: e.EntityState,
+		})
+	}
+	sort.Sort(snapshotEntities(s.Entities))
+	return s
+}
+
+// Get returns a snapshot from its id, if it is still in the ring
+func (r *SnapshotRing) Get(id uint32) (*Snapshot, bool) {
+	if id >= r.next || r.next-id > uint32(len(r.snapshots)) {
+		return nil, false
+	}
+	return &r.snapshots[id%uint32(len(r.snapshots))], true
+}
+
+// Each calls a function for every snapshot of the ring, from the oldest to the
+// newest one
+func (r *SnapshotRing) Each(f func(*Snapshot)) {
+	first := uint32(0)
+	if r.next > uint32(len(r.snapshots)) {
+		first = r.next - uint32(len(r.snapshots))
+	}
+	for id := first; id < r.next; id++ {
+		f(&r.snapshots[id%uint32(len(r.snapshots))])
+	}
+}
+
+// FindPlayer returns the state of a player in a snapshot
+func (s *Snapshot) FindPlayer(id byte) (*SnapshotPlayer, bool) {
+	i := sort.Search(len(s.Players), func(i int) bool {
+		return s.Players[i].Id >= id
+	})
+	if i == len(s.Players) || s.Players[i].Id != id {
+		return nil, false
+	}
+	return &s.Players[i], true
+}
+
+// FindEntity returns the state of an entity in a snapshot
+func (s *Snapshot) FindEntity(netId uint16) (*SnapshotEntity, bool) {
+	i := sort.Search(len(s.Entities), func(i int) bool {
+		return s.Entities[i].NetId >= netId
+	})
+	if i == len(s.Entities) || s.Entities[i].NetId != netId {
+		return nil, false
+	}
+	return &s.Entities[i], true
+}
+
+// Packet generates packets used to broadcast a snapshot to a specific player.
+// Values are sent as deltas from the last state of each element acknowledged
+// by the player, or entirely if it is not available anymore. Only the players
+// of the instance of the receiver in its area of interest are sent, as well
+// as the players who just left the instance so that the client sees their new
+// instance. Players known by the client who left its area of interest are
+// sent as ['O'][id] until the client acknowledges it. Elements are sent by
+// priority within the bandwidth budget of the receiver.
+func (s *Snapshot) Packet(receiver *Player) []*packet.Packet {
+	if receiver.View == nil {
+		receiver.View = NewClientView()
+	}
+	view := receiver.View
+	view.Prune()
+	instance, ok := s.instanceOf(receiver.Account)
+	if !ok {
+		instance = receiver.Instance
+	}
+
+	elements := make([]*snapshotElement, 0, len(s.Players)+len(s.Entities))
+	removed, markers := make([]uint32, 0), make([][]byte, 0)
+	addElement := func(key uint32, data []byte, x, y, z float32) {
+		// Unchanged elements do not need to be sent
+		if len(data) == 0 {
+			return
+		}
+		view.Priority[key] += interestWeight(receiver, x, y, z)
+		elements = append(elements, &snapshotElement{
+			Key:      key,
+			Data:     data,
+			Priority: view.Priority[key],
+		})
+	}
+
+	for j := range s.Players {
+		p1 := &s.Players[j]
+		// Do not send the receiver to itself: players using the input
+		// movement mode get their own state in the header of the packet
+		if p1.Account == receiver.Account {
+			continue
+		}
+
+		// Search for the state of the player known by the receiver, if the
+		// receiver could see it
+		key := playerKey(p1.Id)
+		var p2 *PlayerState
+		if known, ok := view.KnownSnapshot(key); ok {
+			previous, ok := known.FindPlayer(p1.Id)
+			knownInstance, _ := known.instanceOf(receiver.Account)
+			if ok && previous.Account == p1.Account &&
+				instanceVisible(knownInstance, previous.Instance) {
+				p2 = &previous.PlayerState
+			}
+		}
+		if !instanceVisible(instance, p1.Instance) {
+			if p2 == nil {
+				continue
+			}
+		} else if !InInterest(receiver, p1.X, p1.Y, p1.Z) {
+			// The client stops drawing players out of its interest
+			if _, ok := view.Known[key]; ok {
+				removed = append(removed, key)
+				markers = append(markers, []byte{'O', p1.Id})
+			}
+			continue
+		}
+		addElement(key, EncodePlayer([]byte{'A', p1.Id}, &p1.PlayerState, p2),
+			p1.X, p1.Y, p1.Z)
+	}
+
+	for j := range s.Entities {
+		e1 := &s.Entities[j]
+		key := entityKey(e1.NetId)
+		var e2 *EntityState
+		if known, ok := view.KnownSnapshot(key); ok {
+			previous, ok := known.FindEntity(e1.NetId)
+			if ok && previous.UUID == e1.UUID {
+				e2 = &previous.EntityState
+			}
+		}
+		addElement(key, EncodeEntity(append([]byte{'E'},
+			entityIdBytes(e1.NetId)...), &e1.EntityState, e2), e1.X, e1.Y, e1.Z)
+	}
+
+	packets, i := []*packet.Packet{newSnapshotPacket(s.Id, receiver)}, 0
+	addData := func(data []byte) {
+		// Smooth splitting
+		if len(packets[i].Data)+len(data)+2 > packet.PacketSize {
+			packets = append(packets, newSnapshotPacket(s.Id, receiver))
+			i++
+		}
+		packets[i].AddField(data)
+	}
+	sent := make([]uint32, 0, len(elements))
+	for _, e := range prioritize(elements, tickBudget()) {
+		addData(e.Data)
+		view.Priority[e.Key] = 0
+		sent = append(sent, e.Key)
+	}
+	// Markers are small and always sent
+	for _, marker := range markers {
+		addData(marker)
+	}
+	view.Sent[s.Id] = sent
+	if len(removed) > 0 {
+		view.Removed[s.Id] = removed
+	}
+	return packets
+}
+
+// Acknowledge saves a snapshot received by the client: the states it
+// contained are used as references for delta compression
+func (p *Player) Acknowledge(id uint32) bool {
+	snapshot, ok := snapshots.Get(id)
+	if !ok {
+		return false
+	}
+	if p.View == nil {
+		p.View = NewClientView()
+	}
+	p.View.Acknowledge(id)
+	p.UpdateLatency(time.Since(snapshot.Time))
+	return true
+}
+
+// newSnapshotPacket creates an empty world packet (0x04) for a snapshot. The
+// packets sent to players using the input movement mode also contain the
+// sequence of their last simulated input command and the state the server
+// computed from it, so that clients can reconcile their prediction.
+func newSnapshotPacket(uuid uint32, receiver *Player) *packet.Packet {
+	p := packet.New(packet.PacketTypeUDP, 0x04)
+	idBuf := bytes.NewBuffer(nil)
+	binary.Write(idBuf, binary.LittleEndian, uuid)
+	if receiver.InputMode {
+		binary.Write(idBuf, binary.LittleEndian, receiver.InputAck())
+	}
+	p.AddField(idBuf.Bytes())
+	return p
+}
+
+// Sorting helpers for snapshot content
+
+type snapshotPlayers []SnapshotPlayer
+
+func (s snapshotPlayers) Len() int           { return len(s) }
+func (s snapshotPlayers) Less(i, j int) bool { return s[i].Id < s[j].Id }
+func (s snapshotPlayers) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type snapshotEntities []SnapshotEntity
+
+func (s snapshotEntities) Len() int           { return len(s) }
+func (s snapshotEntities) Less(i, j int) bool { return s[i].NetId < s[j].NetId }
+func (s snapshotEntities) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
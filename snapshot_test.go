@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSnapshotRing(t *testing.T) {
+	snapshots = NewSnapshotRing(4)
+	p := &Player{Account: "a", Initialized: true}
+	players = map[byte]*Player{3: p}
+	for i := 0; i < 6; i++ {
+		p.X = float32(i)
+		snapshots.Save()
+	}
+
+	// Only the last 4 snapshots are still available
+	if _, ok := snapshots.Get(1); ok {
+		t.Log("An overwritten snapshot is still available")
+		t.Fail()
+	}
+	if _, ok := snapshots.Get(6); ok {
+		t.Log("A future snapshot is available")
+		t.Fail()
+	}
+	s, ok := snapshots.Get(4)
+	if !ok {
+		t.Log("A recent snapshot is not available")
+		t.Fail()
+		return
+	}
+	if state, ok := s.FindPlayer(3); !ok || state.X != 4 {
+		t.Log("Wrong player state in the snapshot")
+		t.Fail()
+	}
+
+	count := 0
+	snapshots.Each(func(*Snapshot) { count++ })
+	if count != 4 {
+		t.Log("Wrong number of snapshots in the ring:", count)
+		t.Fail()
+	}
+	players = make(map[byte]*Player)
+}
+
+func TestSnapshotInstances(t *testing.T) {
+	config = &defaultConfig
+	if err := SetupDeltaEncoders(); err != nil {
+		t.Log("Couldn't set up delta encoders:", err)
+		t.FailNow()
+	}
+	snapshots = NewSnapshotRing(4)
+	receiver := &Player{Account: "a", Initialized: true}
+	same := &Player{Account: "b", Initialized: true}
+	other := &Player{Account: "c", Initialized: true}
+	other.Instance = 3
+	players = map[byte]*Player{1: receiver, 10: same, 20: other}
+	defer func() {
+		players = make(map[byte]*Player)
+	}()
+
+	sent := func(s *Snapshot, id byte) bool {
+		for _, p := range s.Packet(receiver) {
+			for i := 0; i+1 < len(p.Data); i++ {
+				if p.Data[i] == 'A' && p.Data[i+1] == id {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	s := snapshots.Save()
+	if !sent(s, 10) || sent(s, 20) {
+		t.Log("Players of other instances are visible")
+		t.Fail()
+	}
+
+	// Players leaving the instance are sent once more
+	receiver.Acknowledge(s.Id)
+	same.Instance = 3
+	s = snapshots.Save()
+	if !sent(s, 10) {
+		t.Log("The player leaving the instance has not been sent")
+		t.Fail()
+	}
+	receiver.Acknowledge(s.Id)
+	same.X = 5
+	s = snapshots.Save()
+	if sent(s, 10) {
+		t.Log("The player who left the instance is still sent")
+		t.Fail()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	// Enemies further than this distance do not lower the score of a spawn
+	SpawnSafeDistance = 30
+	// Time during which a spawn point that has just been used is avoided
+	SpawnRecentTime = 5 * time.Second
+	// Lowest factor applied to the score of a spawn point
+	spawnMinFactor = 0.05
+)
+
+// SelectSpawnPoint chooses where a player should appear on the current map.
+// Spawn points are chosen randomly, favoring the ones far from enemies and
+// that have not been used recently.
+func SelectSpawnPoint(p *Player) *SpawnPoint {
+	def, now := CurrentMapDefinition(), time.Now()
+
+	// Spawn points of the team of the player, or without any team
+	teamSpawns := false
+	for i := range def.Spawns {
+		if p.Team != TeamNone && def.Spawns[i].Team == p.Team {
+			teamSpawns = true
+			break
+		}
+	}
+
+	scores, total := make([]float64, len(def.Spawns)), 0.0
+	for i := range def.Spawns {
+		if teamSpawns && def.Spawns[i].Team != TeamNone &&
+			def.Spawns[i].Team != p.Team {
+			continue
+		}
+		scores[i] = SpawnScore(&def.Spawns[i], p, now)
+		total += scores[i]
+	}
+	if total == 0 {
+		return &def.Spawns[rand.Intn(len(def.Spawns))]
+	}
+
+	choice := rand.Float64() * total
+	for i := range def.Spawns {
+		choice -= scores[i]
+		if choice <= 0 {
+			return &def.Spawns[i]
+		}
+	}
+	return &def.Spawns[len(def.Spawns)-1]
+}
+
+// SpawnScore computes how good a spawn point is for a player
+func SpawnScore(spawn *SpawnPoint, p *Player, now time.Time) float64 {
+	score := float64(spawn.Weight)
+
+	// Distance to the closest enemy
+	closest := math.Inf(1)
+	for _, currentPlayer := range players {
+		if currentPlayer.Equals(p) || !currentPlayer.Initialized ||
+			!currentPlayer.IsAlive() ||
+			(p.Team != TeamNone && currentPlayer.Team == p.Team) {
+			continue
+		}
+		dx, dy, dz := float64(currentPlayer.X-spawn.X),
+			float64(currentPlayer.Y-spawn.Y), float64(currentPlayer.Z-spawn.Z)
+		closest = math.Min(closest, math.Sqrt(dx*dx+dy*dy+dz*dz))
+	}
+	score *= clampFactor(closest / SpawnSafeDistance)
+
+	// Spawn points used recently
+	if !spawn.LastUsed.IsZero() {
+		score *= clampFactor(float64(now.Sub(spawn.LastUsed)) /
+			float64(SpawnRecentTime))
+	}
+	return score
+}
+
+// MoveToSpawn places a player on a spawn point chosen by the server
+func (p *Player) MoveToSpawn() {
+	spawn := SelectSpawnPoint(p)
+	spawn.LastUsed = time.Now()
+	p.SetPosition(spawn.X, spawn.Y, spawn.Z)
+	p.YRotation = spawn.Rotation
+}
+
+// CheckSpawnProtection removes the spawn protection of a player once it is
+// over
+func (p *Player) CheckSpawnProtection() {
+	if !p.SpawnProtection.IsZero() && time.Now().After(p.SpawnProtection) {
+		p.EndSpawnProtection()
+	}
+}
+
+// EndSpawnProtection removes the spawn protection of a player, if any
+func (p *Player) EndSpawnProtection() {
+	if p.SpawnProtection.IsZero() {
+		return
+	}
+	p.SpawnProtection = time.Time{}
+	p.Godmode = false
+}
+
+// clampFactor keeps a score factor between its minimum value and 1
+func clampFactor(f float64) float64 {
+	return math.Max(spawnMinFactor, math.Min(1, f))
+}
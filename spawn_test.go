@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpawnScore(t *testing.T) {
+	now := time.Now()
+	p := &Player{Account: "player"}
+	enemy := &Player{Account: "enemy", Initialized: true}
+	enemy.LifeState = LifeStateAlive
+	players = map[byte]*Player{0: p, 1: enemy}
+
+	near := &SpawnPoint{X: 1, Weight: 1}
+	far := &SpawnPoint{X: 100, Weight: 1}
+	if SpawnScore(near, p, now) >= SpawnScore(far, p, now) {
+		t.Log("A spawn point near an enemy has a better score")
+		t.Fail()
+	}
+
+	used := &SpawnPoint{X: 100, Weight: 1, LastUsed: now}
+	if SpawnScore(used, p, now) >= SpawnScore(far, p, now) {
+		t.Log("A spawn point used recently has a better score")
+		t.Fail()
+	}
+
+	heavy := &SpawnPoint{X: 100, Weight: 3}
+	if SpawnScore(heavy, p, now) != 3*SpawnScore(far, p, now) {
+		t.Log("Spawn weights are not applied")
+		t.Fail()
+	}
+	players = make(map[byte]*Player)
+}
+
+func TestGodmodeDuringSpawnProtection(t *testing.T) {
+	p := &Player{Account: "player"}
+	p.SpawnProtection = time.Now().Add(time.Second)
+	p.Godmode = true
+	if result := HandleGodmodeCommand(nil, p); !p.Godmode ||
+		!p.SpawnProtection.IsZero() {
+		t.Log("The god mode of the spawn protection has been toggled:", result)
+		t.Fail()
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/deimosgame/deimos-server/packet"
+)
+
+// IsPlaying checks if a player has joined the game as a combatant, and not as
+// a spectator
+func (p *Player) IsPlaying() bool {
+	return p.Initialized && !p.Spectating
+}
+
+// PlayerCounts returns the number of combatants and of spectators
+func PlayerCounts() (int, int) {
+	playing, spectating := 0, 0
+	for _, currentPlayer := range players {
+		if currentPlayer.IsPlaying() {
+			playing++
+		} else if currentPlayer.Initialized {
+			spectating++
+		}
+	}
+	return playing, spectating
+}
+
+// CanJoin checks if a player may join the game as a combatant, making room by
+// removing a bot if needed
+func CanJoin() bool {
+	playing, _ := PlayerCounts()
+	if playing < config.MaxPlayers {
+		return true
+	}
+	return KickBot()
+}
+
+// CanSpectate checks if there is room for another spectator
+func CanSpectate() bool {
+	_, spectating := PlayerCounts()
+	return spectating < config.MaxSpectators
+}
+
+// Spectate moves a player out of the world: spectators still get the world
+// updates but can neither deal nor take damage
+func (p *Player) Spectate() error {
+	if p.Spectating {
+		return errors.New("already spectating")
+	} else if p.IsBot() {
+		return errors.New("bots can't spectate")
+	} else if !CanSpectate() {
+		return errors.New("too many spectators")
+	}
+	gameMode.OnLeave(p)
+	if session := FindMinigame(p); session != nil {
+		session.End(p)
+	}
+	DestroyProjectiles(p)
+	p.Spectating = true
+	p.Team = TeamNone
+	p.PlaceOnMap()
+	p.SendFollow()
+	MarkScoreboardDirty()
+	SendMessage(p.Name + " is now spectating.")
+
+	// Spectators following this player go back to a free camera
+	for _, currentPlayer := range players {
+		if currentPlayer.Following == p {
+			currentPlayer.Follow(nil)
+		}
+	}
+	return nil
+}
+
+// Play moves a spectator back into the game
+func (p *Player) Play() error {
+	if !p.Spectating {
+		return errors.New("already playing")
+	} else if !CanJoin() {
+		return errors.New("the server is full")
+	}
+	p.Spectating = false
+	p.Following = nil
+	gameMode.OnJoin(p)
+	p.PlaceOnMap()
+	p.SendFollow()
+	MarkScoreboardDirty()
+	SendMessage(p.Name + " has joined the game!")
+	return nil
+}
+
+// Follow makes a spectator follow a player, or use a free camera when the
+// player is nil
+func (p *Player) Follow(target *Player) error {
+	if !p.Spectating {
+		return errors.New("only spectators can follow players")
+	}
+	if target != nil && (!target.IsPlaying() || !p.CanSee(target)) {
+		return errors.New("this player can't be followed")
+	}
+	p.Following = target
+	p.SendFollow()
+	return nil
+}
+
+// CheckFollow moves a spectator along with the player they follow, so that
+// they get the updates relevant to this player
+func (p *Player) CheckFollow() {
+	target := p.Following
+	if target == nil {
+		return
+	}
+	if _, ok := target.Id(); !ok || !target.IsPlaying() || !p.CanSee(target) {
+		p.Follow(nil)
+		return
+	}
+	p.X, p.Y, p.Z = target.X, target.Y, target.Z
+	p.XRotation, p.YRotation = target.XRotation, target.YRotation
+}
+
+// SendFollow tells a player whether they are spectating and which player they
+// follow (0x19): [spectating][player id]
+func (p *Player) SendFollow() {
+	spectating, followed := byte(0), byte(NoPlayerId)
+	if p.Spectating {
+		spectating = 1
+	}
+	if p.Following != nil {
+		if id, ok := p.Following.Id(); ok {
+			followed = id
+		}
+	}
+	followPacket := packet.New(packet.PacketTypeTCP, 0x19)
+	followPacket.AddFieldBytes(spectating, followed)
+	p.Send(followPacket)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestSpectators(t *testing.T) {
+	previousConfig, previousMode, previousMap, previousDefinitions := config,
+		gameMode, currentMap, mapDefinitions
+	previousPlayers, previousSnapshots, previousLog := players, snapshots,
+		log
+	udpNetworkInput := UdpNetworkInput
+	testConfig := defaultConfig
+	testConfig.MaxPlayers, testConfig.MaxSpectators = 2, 1
+	config = &testConfig
+	gameMode = NewDeathmatchMode()
+	currentMap = "test"
+	mapDefinitions = map[string]*MapDefinition{
+		"test": DefaultMapDefinition("test")}
+	a, b, c := newTestPlayer("a"), newTestPlayer("b"), newTestPlayer("c")
+	players = map[byte]*Player{0: a, 1: b, 2: c}
+	snapshots = NewSnapshotRing(2)
+	// Chat messages are logged and sent through the UDP queue
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	defer func() {
+		config, gameMode, currentMap, mapDefinitions = previousConfig,
+			previousMode, previousMap, previousDefinitions
+		log.Close()
+		players, snapshots, log = previousPlayers, previousSnapshots,
+			previousLog
+		UdpNetworkInput = udpNetworkInput
+		os.Remove("test.log")
+	}()
+
+	if err := a.Spectate(); err != nil || a.IsAlive() ||
+		a.ScoreboardFlags()&ScoreboardFlagSpectator == 0 {
+		t.Log("The player is not spectating:", err)
+		t.FailNow()
+	}
+	if _, ok := snapshots.Save().FindPlayer(0); ok {
+		t.Log("The spectator appears in the world")
+		t.Fail()
+	}
+	InflictDamage(a, b, 10)
+	if b.Health != MaxHealth {
+		t.Log("The spectator has dealt damage")
+		t.Fail()
+	}
+	if err := b.Spectate(); err == nil {
+		t.Log("The spectator limit has not been enforced")
+		t.Fail()
+	}
+	if HandleSpectateCommand([]string{"c"}, a); c.Spectating {
+		t.Log("A player who is not an operator moved another player")
+		t.Fail()
+	}
+
+	// Spectators move with the player they follow
+	if err := a.Follow(b); err != nil {
+		t.Log("Couldn't follow a player:", err)
+		t.FailNow()
+	}
+	b.X = 42
+	a.CheckFollow()
+	if a.X != 42 {
+		t.Log("The spectator has not followed the player")
+		t.Fail()
+	}
+	b.Instance = 3
+	a.CheckFollow()
+	if a.Following != nil {
+		t.Log("The spectator still follows a player out of sight")
+		t.Fail()
+	}
+
+	// Spectators only play again when there is room for them
+	if err := a.Play(); err == nil {
+		t.Log("The player limit has not been enforced")
+		t.Fail()
+	}
+	testConfig.MaxPlayers = 3
+	if err := a.Play(); err != nil || !a.IsAlive() || a.Spectating {
+		t.Log("The spectator couldn't play again:", err)
+		t.Fail()
+	}
+}
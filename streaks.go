@@ -0,0 +1,122 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// Time during which damage counts towards an assist
+	AssistTime = 10 * time.Second
+)
+
+// DamageRecord is an entry of the damage history of a player
+type DamageRecord struct {
+	Attacker *Player
+	Damage   int
+	Time     time.Time
+}
+
+// RecordDamage adds damage done by another player to the history of a player
+func (p *Player) RecordDamage(attacker *Player, damage int) {
+	if attacker == nil || attacker.Equals(p) || damage <= 0 {
+		return
+	}
+	now := time.Now()
+	history := p.DamageHistory[:0]
+	for _, record := range p.DamageHistory {
+		if now.Sub(record.Time) < AssistTime {
+			history = append(history, record)
+		}
+	}
+	p.DamageHistory = append(history, DamageRecord{
+		Attacker: attacker,
+		Damage:   damage,
+		Time:     now,
+	})
+}
+
+// Assisters returns the players, other than the killer, who recently did
+// enough damage to a victim to get an assist
+func Assisters(victim, killer *Player) []*Player {
+	damage := make(map[string]int)
+	assisters := make([]*Player, 0)
+	now := time.Now()
+	for _, record := range victim.DamageHistory {
+		if now.Sub(record.Time) >= AssistTime || record.Attacker.Equals(killer) {
+			continue
+		}
+		account := record.Attacker.Account
+		damage[account] += record.Damage
+		if damage[account]-record.Damage < config.AssistThreshold &&
+			damage[account] >= config.AssistThreshold {
+			assisters = append(assisters, record.Attacker)
+		}
+	}
+	return assisters
+}
+
+// CreditKill updates the streaks, multi-kills and assists related to a kill
+// and returns the players who got an assist
+func CreditKill(victim, killer *Player) []*Player {
+	assisters := make([]*Player, 0)
+	if !killer.Equals(victim) {
+		killer.CurrentStreak++
+		if killer.CurrentStreak > killer.BestStreak {
+			killer.BestStreak = killer.CurrentStreak
+		}
+
+		now := time.Now()
+		if now.Sub(killer.LastKill) <= time.Duration(config.MultiKillWindow)*
+			time.Millisecond {
+			killer.MultiKill++
+		} else {
+			killer.MultiKill = 1
+		}
+		killer.LastKill = now
+
+		assisters = Assisters(victim, killer)
+		for _, assister := range assisters {
+			assister.Assists++
+		}
+		announceKill(killer)
+	}
+	victim.CurrentStreak = 0
+	victim.MultiKill = 0
+	victim.DamageHistory = nil
+	return assisters
+}
+
+// announceKill tells everybody about the multi-kills and streaks of a player
+func announceKill(killer *Player) {
+	switch killer.MultiKill {
+	case 1:
+	case 2:
+		SendMessage(killer.Name + " got a double kill!")
+	case 3:
+		SendMessage(killer.Name + " got a triple kill!")
+	default:
+		SendMessage(killer.Name + " got a multi kill!")
+	}
+	switch killer.CurrentStreak {
+	case 5:
+		SendMessage(killer.Name + " is on a killing spree!")
+	case 10:
+		SendMessage(killer.Name + " is on a rampage!")
+	case 20:
+		SendMessage(killer.Name + " is dominating!")
+	}
+}
+
+// ResetStats clears the statistics of a player for a new match
+func (p *Player) ResetStats() {
+	p.Score = 0
+	p.Victims = 0
+	p.Deaths = 0
+	p.Assists = 0
+	p.CurrentStreak = 0
+	p.BestStreak = 0
+	p.MultiKill = 0
+	p.LastKill = time.Time{}
+	p.DamageHistory = nil
+	MarkScoreboardDirty()
+}
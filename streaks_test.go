@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/deimosgame/deimos-server/util"
+)
+
+func TestCreditKill(t *testing.T) {
+	previousConfig, previousPlayers, previousLog := config, players, log
+	udpNetworkInput := UdpNetworkInput
+	config = &defaultConfig
+	killer, victim := newTestPlayer("killer"), newTestPlayer("victim")
+	helper, weak := newTestPlayer("helper"), newTestPlayer("weak")
+	players = map[byte]*Player{0: killer, 1: victim, 2: helper, 3: weak}
+	// Chat messages are logged and sent through the UDP queue
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	defer func() {
+		log.Close()
+		config, players, log = previousConfig, previousPlayers, previousLog
+		UdpNetworkInput = udpNetworkInput
+		os.Remove("test.log")
+	}()
+
+	victim.RecordDamage(helper, config.AssistThreshold)
+	victim.RecordDamage(weak, config.AssistThreshold-1)
+	victim.RecordDamage(killer, 100)
+	assisters := CreditKill(victim, killer)
+	if len(assisters) != 1 || !assisters[0].Equals(helper) ||
+		helper.Assists != 1 || weak.Assists != 0 {
+		t.Log("Wrong assists:", len(assisters), helper.Assists, weak.Assists)
+		t.Fail()
+	}
+	if len(victim.DamageHistory) != 0 {
+		t.Log("The damage history of the victim has not been cleared")
+		t.Fail()
+	}
+
+	// Old damage does not count
+	victim.DamageHistory = []DamageRecord{{Attacker: helper, Damage: 100,
+		Time: time.Now().Add(-AssistTime)}}
+	if len(Assisters(victim, killer)) != 0 {
+		t.Log("Old damage has been counted for an assist")
+		t.Fail()
+	}
+
+	CreditKill(victim, killer)
+	if killer.CurrentStreak != 2 || killer.MultiKill != 2 {
+		t.Log("Wrong streak or multi-kill:", killer.CurrentStreak,
+			killer.MultiKill)
+		t.Fail()
+	}
+	killer.LastKill = time.Now().Add(-time.Duration(config.MultiKillWindow+1) *
+		time.Millisecond)
+	CreditKill(victim, killer)
+	if killer.CurrentStreak != 3 || killer.MultiKill != 1 ||
+		killer.BestStreak != 3 {
+		t.Log("Multi-kill window not respected:", killer.MultiKill)
+		t.Fail()
+	}
+
+	CreditKill(killer, victim)
+	if killer.CurrentStreak != 0 || killer.BestStreak != 3 {
+		t.Log("The streak of the victim has not been reset")
+		t.Fail()
+	}
+}
+
+func TestInstantCooling(t *testing.T) {
+	previousConfig, previousMode, previousPlayers := config, gameMode,
+		players
+	previousLog, udpNetworkInput, apiInput := log, UdpNetworkInput, APIInput
+	config = &defaultConfig
+	gameMode = NewDeathmatchMode()
+	killer, victim := newTestPlayer("killer"), newTestPlayer("victim")
+	players = map[byte]*Player{0: killer, 1: victim}
+	// Chat messages are logged and sent through the UDP queue
+	log = util.InitLogging("test.log")
+	UdpNetworkInput = make(chan *UDPOutboundMessage, 1000)
+	APIInput = make(chan *APIRequest, 10)
+	defer func() {
+		config, gameMode, players = previousConfig, previousMode,
+			previousPlayers
+		log.Close()
+		log, UdpNetworkInput, APIInput = previousLog, udpNetworkInput,
+			apiInput
+		os.Remove("test.log")
+	}()
+	unlocked := func() bool {
+		for {
+			select {
+			case req := <-APIInput:
+				if req.Player.Equals(killer) && req.AchievementId == 12 {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}
+
+	// Players on a streak do not get the achievement for any kill
+	killer.CurrentStreak = 6
+	BroadcastKill(victim, killer)
+	if unlocked() {
+		t.Log("The achievement has been granted to a player on a streak")
+		t.Fail()
+	}
+
+	// Ending the streak of someone else grants it
+	killer.CurrentStreak, victim.CurrentStreak = 0, 6
+	BroadcastKill(victim, killer)
+	if !unlocked() {
+		t.Log("The achievement has not been granted for ending a streak")
+		t.Fail()
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	TeamNone = byte(iota)
+	TeamRed
+	TeamBlue
+)
+
+const (
+	FriendlyFireOff     = "off"
+	FriendlyFireOn      = "on"
+	FriendlyFireReflect = "reflect"
+	FriendlyFireHalf    = "half"
+)
+
+// IsTeammate checks if two different players are in the same team
+func (p *Player) IsTeammate(p2 *Player) bool {
+	return p.Team != TeamNone && p.Team == p2.Team && !p.Equals(p2)
+}
+
+// SetTeam moves a player to another team. Living players are moved to a spawn
+// point of their new team.
+func (p *Player) SetTeam(team byte) {
+	if p.Team == team {
+		return
+	}
+	p.Team = team
+	if config.ServerHealth && p.IsAlive() {
+		p.PlaceOnMap()
+	}
+	UpdatePlayerList()
+}
+
+// FriendlyFireDamage applies the friendly fire policy to damage. It returns
+// the damage to apply to the victim and the damage reflected on the attacker.
+func FriendlyFireDamage(attacker, victim *Player, damage int) (int, int) {
+	if !attacker.IsTeammate(victim) {
+		return damage, 0
+	}
+	switch config.FriendlyFire {
+	case FriendlyFireOn:
+		return damage, 0
+	case FriendlyFireReflect:
+		return 0, damage
+	case FriendlyFireHalf:
+		return damage / 2, 0
+	}
+	return 0, 0
+}
+
+// BalanceTeams moves dead players from the biggest team to the smallest one
+// when the difference is more than one player
+func BalanceTeams() {
+	if !config.AutoBalance {
+		return
+	}
+	counts := TeamCounts(nil)
+	from, to := TeamRed, TeamBlue
+	if counts[TeamBlue] > counts[TeamRed] {
+		from, to = TeamBlue, TeamRed
+	}
+	if counts[from]-counts[to] < 2 {
+		return
+	}
+	// Players are only moved while dead, so that they do not lose a life
+	for _, currentPlayer := range players {
+		if currentPlayer.Initialized && currentPlayer.Team == from &&
+			!currentPlayer.IsAlive() {
+			currentPlayer.SetTeam(to)
+			SendMessage(currentPlayer.Name + " has been moved to the " +
+				TeamName(to) + " to balance teams.")
+			return
+		}
+	}
+}
+
+// SmallestTeam returns the team with the fewest players, not counting a given
+// player
+func SmallestTeam(p *Player) byte {
+	counts := TeamCounts(p)
+	if counts[TeamBlue] < counts[TeamRed] {
+		return TeamBlue
+	}
+	return TeamRed
+}
+
+// TeamCounts counts the players of each team, not counting a given player
+// (nil to count everybody)
+func TeamCounts(p *Player) [3]int {
+	var counts [3]int
+	for _, currentPlayer := range players {
+		if currentPlayer.IsPlaying() &&
+			(p == nil || !currentPlayer.Equals(p)) &&
+			int(currentPlayer.Team) < len(counts) {
+			counts[currentPlayer.Team]++
+		}
+	}
+	return counts
+}
+
+// teamLeader returns the name and the score of the team with the highest
+// score, with an empty name when both teams are tied
+func teamLeader(scores [3]int) (string, int) {
+	switch {
+	case scores[TeamRed] > scores[TeamBlue]:
+		return TeamName(TeamRed), scores[TeamRed]
+	case scores[TeamBlue] > scores[TeamRed]:
+		return TeamName(TeamBlue), scores[TeamBlue]
+	}
+	return "", scores[TeamRed]
+}
+
+// TeamName returns the display name of a team
+func TeamName(team byte) string {
+	switch team {
+	case TeamRed:
+		return "Red team"
+	case TeamBlue:
+		return "Blue team"
+	}
+	return "No team"
+}
+
+// ParseTeam reads a team from its name or its number
+func ParseTeam(name string) (byte, error) {
+	switch strings.ToLower(name) {
+	case "none", "0":
+		return TeamNone, nil
+	case "red", "1":
+		return TeamRed, nil
+	case "blue", "2":
+		return TeamBlue, nil
+	}
+	return TeamNone, errors.New("Unknown team " + name)
+}
+
+// SendTeamMessage sends a chat message from a player to the members of its
+// team who can see this player
+func SendTeamMessage(sender *Player, message string) {
+	for _, currentPlayer := range players {
+		if currentPlayer.Initialized && currentPlayer.Team == sender.Team &&
+			currentPlayer.CanSee(sender) {
+			currentPlayer.SendMessage(message)
+		}
+	}
+}
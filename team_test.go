@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFriendlyFireDamage(t *testing.T) {
+	testConfig := defaultConfig
+	config = &testConfig
+	defer func() {
+		config = &defaultConfig
+	}()
+	red := &Player{Account: "red"}
+	red.Team = TeamRed
+	red2 := &Player{Account: "red2"}
+	red2.Team = TeamRed
+	blue := &Player{Account: "blue"}
+	blue.Team = TeamBlue
+
+	policies := []struct {
+		policy            string
+		victim, reflected int
+	}{
+		{FriendlyFireOff, 0, 0},
+		{FriendlyFireOn, 40, 0},
+		{FriendlyFireHalf, 20, 0},
+		{FriendlyFireReflect, 0, 40},
+	}
+	for _, test := range policies {
+		config.FriendlyFire = test.policy
+		victim, reflected := FriendlyFireDamage(red, red2, 40)
+		if victim != test.victim || reflected != test.reflected {
+			t.Log("Wrong damage with policy", test.policy, victim, reflected)
+			t.Fail()
+		}
+		if victim, reflected := FriendlyFireDamage(red, blue, 40); victim != 40 ||
+			reflected != 0 {
+			t.Log("Damage on enemies changed with policy", test.policy)
+			t.Fail()
+		}
+		if victim, _ := FriendlyFireDamage(red, red, 40); victim != 40 {
+			t.Log("Self damage changed with policy", test.policy)
+			t.Fail()
+		}
+	}
+}
+
+func TestParseTeam(t *testing.T) {
+	for name, expected := range map[string]byte{"Red": TeamRed, "2": TeamBlue,
+		"none": TeamNone} {
+		if team, err := ParseTeam(name); err != nil || team != expected {
+			t.Log("Couldn't parse team", name)
+			t.Fail()
+		}
+	}
+	if _, err := ParseTeam("green"); err == nil {
+		t.Log("Parsed an unknown team")
+		t.Fail()
+	}
+}
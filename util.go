@@ -60,7 +60,21 @@ func Heartbeat() {
 			log.Notice("Regained connection with the master server")
 			masterServerLost = false
 		}
-		time.Sleep(HeartbeatInterval)
+
+		// Wait for the next heartbeat, or for a change to announce
+		select {
+		case <-heartbeatTrigger:
+		case <-time.After(HeartbeatInterval):
+		}
+	}
+}
+
+// TriggerHeartbeat sends a heartbeat as soon as possible, for instance when the
+// current map changes
+func TriggerHeartbeat() {
+	select {
+	case heartbeatTrigger <- true:
+	default:
 	}
 }
 
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Part of the fire interval of a weapon accepted between two shots, to
+	// allow some network jitter
+	FireRateTolerance = 0.8
+	// Distance added to the range of weapons for inaccurate positions
+	RangeTolerance = 2
+)
+
+var (
+	weapons = make(map[byte]*Weapon)
+)
+
+// Weapon describes a weapon, as written in the weapons file
+type Weapon struct {
+	Id   byte   `json:"id"`
+	Name string `json:"name"`
+	// Damage of a single pellet at close range
+	Damage  int `json:"damage"`
+	Pellets int `json:"pellets"`
+	// Shots per second
+	FireRate float64 `json:"fire_rate"`
+	// Maximum distance of a hit (0 for no limit)
+	Range float32 `json:"range"`
+	// Distance from which damage decreases, down to FalloffMin times the
+	// damage at the range of the weapon
+	FalloffStart float32 `json:"falloff_start"`
+	FalloffMin   float32 `json:"falloff_min"`
+	// Size of a magazine
+	Ammo int `json:"ammo"`
+
+	// Speed of the projectiles simulated by the server (0 for hitscan
+	// weapons, whose damage is sent by clients)
+	ProjectileSpeed float32 `json:"projectile_speed"`
+	ProjectileModel string  `json:"projectile_model"`
+	// Whether or not projectiles fall and only explode at the end of their
+	// fuse (in seconds) or when touching a player
+	Ballistic bool    `json:"ballistic"`
+	Fuse      float64 `json:"fuse"`
+	// Distance at which explosions still do FalloffMin times the damage
+	SplashRadius float32 `json:"splash_radius"`
+}
+
+// LoadWeapons reads the weapon table. Without weapons file, damage sent by
+// clients is not validated.
+func LoadWeapons() {
+	data, err := ioutil.ReadFile(config.WeaponsFile)
+	if os.IsNotExist(err) {
+		log.Warn("No weapons file found, damage will not be validated")
+		return
+	} else if err != nil {
+		log.Panic("Couldn't read the weapons file: " + err.Error())
+	}
+	table, err := ParseWeapons(data)
+	if err != nil {
+		log.Panic("Invalid weapons file: " + err.Error())
+	}
+	weapons = table
+}
+
+// ParseWeapons decodes and validates a weapon table
+func ParseWeapons(data []byte) (map[byte]*Weapon, error) {
+	list := make([]*Weapon, 0)
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	table := make(map[byte]*Weapon)
+	for _, weapon := range list {
+		if _, ok := table[weapon.Id]; ok {
+			return nil, errors.New("weapon " + strconv.Itoa(int(weapon.Id)) +
+				" is defined twice")
+		}
+		if err := weapon.Validate(); err != nil {
+			return nil, errors.New("weapon " + strconv.Itoa(int(weapon.Id)) +
+				": " + err.Error())
+		}
+		table[weapon.Id] = weapon
+	}
+	return table, nil
+}
+
+// Validate checks the consistency of a weapon definition
+func (w *Weapon) Validate() error {
+	if w.Pellets == 0 {
+		w.Pellets = 1
+	}
+	switch {
+	case w.Damage <= 0:
+		return errors.New("damage must be positive")
+	case w.Pellets < 0:
+		return errors.New("negative pellet count")
+	case w.FireRate <= 0:
+		return errors.New("fire rate must be positive")
+	case w.Range < 0 || w.FalloffStart < 0:
+		return errors.New("negative range")
+	case w.Range > 0 && w.FalloffStart > w.Range:
+		return errors.New("falloff starts after the range")
+	case w.FalloffMin < 0 || w.FalloffMin > 1:
+		return errors.New("falloff minimum must be between 0 and 1")
+	case w.Ammo < 0:
+		return errors.New("negative ammo")
+	case w.ProjectileSpeed < 0 || w.SplashRadius < 0 || w.Fuse < 0:
+		return errors.New("negative projectile value")
+	case w.Ballistic && (w.ProjectileSpeed == 0 || w.Fuse == 0):
+		return errors.New("ballistic weapons need a projectile speed and a fuse")
+	}
+	return nil
+}
+
+// FireInterval returns the minimum time between two shots
+func (w *Weapon) FireInterval() time.Duration {
+	return time.Duration(float64(time.Second) / w.FireRate)
+}
+
+// MaxDamage returns the highest damage a shot can do at a given distance, or
+// 0 when the target is out of range
+func (w *Weapon) MaxDamage(distance float32) int {
+	if w.Range > 0 && distance > w.Range+RangeTolerance {
+		return 0
+	}
+	damage := float32(w.Damage * w.Pellets)
+	if w.Range == 0 || distance <= w.FalloffStart {
+		return int(damage)
+	}
+	progress := (distance - w.FalloffStart) / (w.Range - w.FalloffStart)
+	if progress > 1 {
+		progress = 1
+	}
+	factor := 1 - progress*(1-w.FalloffMin)
+	return int(math.Ceil(float64(damage * factor)))
+}
+
+// HasProjectiles checks if the shots of a weapon are simulated by the server
+func (w *Weapon) HasProjectiles() bool {
+	return w.ProjectileSpeed > 0
+}
+
+// SplashDamage returns the damage of an explosion at a given distance, or 0
+// when it is out of the splash radius
+func (w *Weapon) SplashDamage(distance float32) int {
+	if distance <= 0 {
+		return w.Damage
+	} else if distance > w.SplashRadius {
+		return 0
+	}
+	factor := 1 - distance/w.SplashRadius*(1-w.FalloffMin)
+	return int(math.Ceil(float64(float32(w.Damage) * factor)))
+}
+
+// ValidateWeaponDamage checks that damage done by a player to another one at
+// a given distance is possible with the current weapon of the attacker
+func ValidateWeaponDamage(attacker *Player, damage int, distance float32) bool {
+	if len(weapons) == 0 {
+		return true
+	}
+	weapon, ok := weapons[attacker.CurrentWeapon]
+	if !ok || weapon.HasProjectiles() {
+		// Explosions are resolved by the server
+		return false
+	}
+
+	// Damage arriving before the end of the fire interval belongs to the same
+	// shot (several pellets or victims)
+	now := time.Now()
+	if now.Sub(attacker.LastShot) >= time.Duration(
+		float64(weapon.FireInterval())*FireRateTolerance) {
+		attacker.LastShot = now
+		attacker.ShotDamage = 0
+	}
+	if attacker.ShotDamage+damage > weapon.MaxDamage(distance) {
+		return false
+	}
+	attacker.ShotDamage += damage
+	return true
+}
+
+// distance returns the distance between a player and a point
+func (p *Player) distance(x, y, z float32) float32 {
+	dx, dy, dz := p.X-x, p.Y-y, p.Z-z
+	return float32(math.Sqrt(float64(dx*dx + dy*dy + dz*dz)))
+}
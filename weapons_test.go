@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestParseWeapons(t *testing.T) {
+	invalid := map[string]string{
+		"no damage":      `[{"id": 1, "fire_rate": 1}]`,
+		"no fire rate":   `[{"id": 1, "damage": 10}]`,
+		"falloff":        `[{"id": 1, "damage": 10, "fire_rate": 1, "range": 5, "falloff_start": 10}]`,
+		"duplicate id":   `[{"id": 1, "damage": 10, "fire_rate": 1}, {"id": 1, "damage": 10, "fire_rate": 1}]`,
+		"negative ammo":  `[{"id": 1, "damage": 10, "fire_rate": 1, "ammo": -1}]`,
+		"syntax error":   `[{"id": 1`,
+		"falloff factor": `[{"id": 1, "damage": 10, "fire_rate": 1, "falloff_min": 2}]`,
+	}
+	for reason, data := range invalid {
+		if _, err := ParseWeapons([]byte(data)); err == nil {
+			t.Log("Invalid weapon table accepted:", reason)
+			t.Fail()
+		}
+	}
+}
+
+func TestValidateWeaponDamage(t *testing.T) {
+	table, err := ParseWeapons([]byte(`[{"id": 3, "damage": 10, "pellets": 4,
+		"fire_rate": 2, "range": 50, "falloff_start": 10, "falloff_min": 0.5}]`))
+	if err != nil {
+		t.Log("Couldn't parse the weapon table:", err)
+		t.FailNow()
+	}
+	weapons = table
+	defer func() {
+		weapons = make(map[byte]*Weapon)
+	}()
+	shotgun := weapons[3]
+	if shotgun.MaxDamage(5) != 40 || shotgun.MaxDamage(30) != 30 ||
+		shotgun.MaxDamage(50) != 20 || shotgun.MaxDamage(60) != 0 {
+		t.Log("Wrong damage falloff:", shotgun.MaxDamage(30))
+		t.Fail()
+	}
+
+	p := &Player{}
+	p.CurrentWeapon = 3
+	// Pellets of a single shot may hit several players
+	if !ValidateWeaponDamage(p, 20, 5) || !ValidateWeaponDamage(p, 20, 5) {
+		t.Log("Valid damage has been rejected")
+		t.Fail()
+	}
+	if ValidateWeaponDamage(p, 10, 5) {
+		t.Log("Damage faster than the fire rate has been accepted")
+		t.Fail()
+	}
+	p.LastShot = time.Now().Add(-shotgun.FireInterval())
+	if !ValidateWeaponDamage(p, 10, 5) {
+		t.Log("Damage of a new shot has been rejected")
+		t.Fail()
+	}
+
+	p.CurrentWeapon = 1
+	if ValidateWeaponDamage(p, 10, 5) {
+		t.Log("Damage from an unknown weapon has been accepted")
+		t.Fail()
+	}
+
+	// The bundled weapon table is valid
+	config = &defaultConfig
+	if data, err := ioutil.ReadFile(config.WeaponsFile); err != nil {
+		t.Log("Couldn't read the bundled weapon table:", err)
+		t.Fail()
+	} else if _, err := ParseWeapons(data); err != nil {
+		t.Log("The bundled weapon table is invalid:", err)
+		t.Fail()
+	}
+}